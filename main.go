@@ -1,24 +1,91 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"registry-sample/producers"
 	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
 	"registry-sample/readers/csv"
 	"registry-sample/readers/loader"
 	"registry-sample/readers/mon"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	dataDir := flag.String("datadir", "./data", "Directory where data files are stored")
 	port := flag.String("port", "5000", "Port to listen requests on")
+	pageSize := flag.Int("pagesize", 50, "Number of rows per page in printable output")
+	timeout := flag.Duration("timeout", 30*time.Second, "Time given to produce output for a request, 0 means no timeout")
+	maxBytes := flag.Int64("maxbytes", 0, "Maximum size of a data file in bytes, 0 means no limit")
+	debug := flag.Bool("debug", false, "Respond with verbose errors to help debugging clients")
+	slow := flag.Duration("slow", 0, "Log requests that take longer than this, 0 means no logging")
+	csvBOM := flag.Bool("csvbom", false, "Start CSV output with UTF-8 byte order mark for Excel")
+	typedJSON := flag.Bool("typedjson", false, "Output credit limits as numbers and unparseable values as null in JSON")
+	shutdownTimeout := flag.Duration("shutdowntimeout", 10*time.Second, "Time to wait for active requests on shutdown")
 	flag.Parse()
 
-	mux := producers.NewServeMux("/")
-	ld := loader.NewFS(*dataDir)
-	mux.AddProducer("csv", spreadsheet.NewProducer(csv.NewReader(ld)))
-	mux.AddProducer("mon", spreadsheet.NewProducer(mon.NewReader(ld)))
+	mux, err := producers.NewServeMuxWithMetrics("/", prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ld := loader.NewFSWithLimit(*dataDir, *maxBytes)
+	specs := map[string]producers.ProducerSpec{
+		"csv-print": {Format: "csv", Loader: ld, PageSize: *pageSize},
+		"mon-print": {Format: "mon", Loader: ld, PageSize: *pageSize},
+	}
+	for _, format := range readers.Formats() {
+		specs[format] = producers.ProducerSpec{Format: format, Loader: ld}
+	}
+	err = producers.Register(mux, specs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux.AddProducer("all", spreadsheet.NewMultiProducer(csv.NewReader(ld), mon.NewReader(ld)))
+	mux.AddProducer("csv-summary", spreadsheet.NewSummaryProducer(csv.NewReader(ld)))
+	jsonl := spreadsheet.NewJSONLProducer(csv.NewReader(ld))
+	jsonl.TypedJSON(*typedJSON)
+	mux.AddProducer("csv-jsonl", jsonl)
+	export := spreadsheet.NewCSVProducer(csv.NewReader(ld))
+	export.WriteByteOrderMark(*csvBOM)
+	mux.AddProducer("csv-export", export)
+
+	root := http.NewServeMux()
+	root.Handle("/metrics", promhttp.Handler())
+	mux.SetHealthPath("/healthz")
+	mux.SetReadyPath("/readyz")
+	mux.SetTimeout(*timeout)
+	mux.SetSlowThreshold(*slow)
+	mux.SetDebug(*debug)
+	root.Handle("/", mux)
+
+	srv := &http.Server{Addr: ":" + *port, Handler: root}
+	drained := make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		defer close(drained)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := mux.Shutdown(ctx); err != nil {
+			log.Println("[SHUTDOWN]", err)
+		}
+	})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		srv.Shutdown(context.Background())
+	}()
 
-	http.ListenAndServe(":"+*port, mux)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-drained
 }
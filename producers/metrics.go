@@ -0,0 +1,63 @@
+package producers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of Producer invocation used as a metric label.
+const (
+	outcomeOK       = "ok"
+	outcomeNotFound = "not_found"
+	outcomeError    = "error"
+	outcomePanic    = "panic"
+	outcomeTimeout  = "timeout"
+)
+
+// metrics holds Prometheus collectors of ServeMux.
+type metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// newMetrics creates collectors and registers them with the specified Registerer.
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	labels := []string{"producer", "outcome"}
+	m := &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registry_requests_total",
+			Help: "Number of requests handled by producers.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "registry_errors_total",
+			Help: "Number of requests producers failed to handle.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "registry_request_duration_seconds",
+			Help:    "Time spent by producers to handle requests.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.errors, m.latency} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// observe records a single Producer invocation. It's safe
+// to call observe on nil metrics, it does nothing.
+func (m *metrics) observe(producer string, outcome string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(producer, outcome).Inc()
+	if outcome == outcomeError || outcome == outcomePanic || outcome == outcomeTimeout {
+		m.errors.WithLabelValues(producer, outcome).Inc()
+	}
+	m.latency.WithLabelValues(producer, outcome).Observe(elapsed.Seconds())
+}
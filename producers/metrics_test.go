@@ -0,0 +1,111 @@
+package producers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// findMetric returns the metric of the specified family that has the
+// specified producer and outcome labels or nil if there is no one.
+func findMetric(t *testing.T, reg *prometheus.Registry, family, producer, outcome string) *dto.Metric {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != family {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["producer"] == producer && labels["outcome"] == outcome {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func serveMetricsTest(mux *ServeMux, url string) {
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+}
+
+func TestNewServeMuxWithMetrics_RegisteredTwice_ErrorReturned(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_, err := NewServeMuxWithMetrics("/", reg)
+	assert.NoError(t, err)
+
+	_, err = NewServeMuxWithMetrics("/", reg)
+	assert.Error(t, err)
+}
+
+func TestServeHTTP_WithMetrics_CountersMovedForLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux, err := NewServeMuxWithMetrics("/", reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.AddProducer("ok", &testProducer{})
+	mux.AddProducer("missing", &testProducer{err: os.ErrNotExist})
+	mux.AddProducer("failed", &testProducer{err: errors.New("sad-but-true")})
+	mux.AddProducer("paniced", &testProducer{panic: "it-happens"})
+
+	serveMetricsTest(mux, "/ok/name")
+	serveMetricsTest(mux, "/ok/name")
+	serveMetricsTest(mux, "/missing/name")
+	serveMetricsTest(mux, "/failed/name")
+	serveMetricsTest(mux, "/paniced/name")
+
+	testCases := []struct {
+		producer string
+		outcome  string
+		requests float64
+		errors   float64
+	}{
+		{producer: "ok", outcome: "ok", requests: 2},
+		{producer: "missing", outcome: "not_found", requests: 1},
+		{producer: "failed", outcome: "error", requests: 1, errors: 1},
+		{producer: "paniced", outcome: "panic", requests: 1, errors: 1},
+	}
+	for _, tc := range testCases {
+		m := findMetric(t, reg, "registry_requests_total", tc.producer, tc.outcome)
+		if assert.NotNil(t, m, "requests %s", tc.producer) {
+			assert.Equal(t, tc.requests, m.GetCounter().GetValue(), "requests %s", tc.producer)
+		}
+
+		m = findMetric(t, reg, "registry_errors_total", tc.producer, tc.outcome)
+		if tc.errors == 0 {
+			assert.Nil(t, m, "errors %s", tc.producer)
+		} else if assert.NotNil(t, m, "errors %s", tc.producer) {
+			assert.Equal(t, tc.errors, m.GetCounter().GetValue(), "errors %s", tc.producer)
+		}
+
+		m = findMetric(t, reg, "registry_request_duration_seconds", tc.producer, tc.outcome)
+		if assert.NotNil(t, m, "latency %s", tc.producer) {
+			assert.Equal(t, uint64(tc.requests), m.GetHistogram().GetSampleCount(), "latency %s", tc.producer)
+		}
+	}
+}
+
+func TestServeHTTP_UnmappedKeyWithMetrics_NothingRecorded(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux, err := NewServeMuxWithMetrics("/", reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveMetricsTest(mux, "/key2/name")
+
+	families, _ := reg.Gather()
+	assert.Len(t, families, 0)
+}
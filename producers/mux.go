@@ -1,16 +1,38 @@
 package producers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Content types of common output formats.
+const (
+	ContentTypeHTML = "text/html; charset=utf-8"
+	ContentTypeJSON = "application/json"
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXML  = "application/xml"
 )
 
+// allowedMethods lists HTTP methods supported for Producer's URLs.
+const allowedMethods = "GET, OPTIONS"
+
 // Producer defines a plugin interface for ServeMux.
 // Taking a named source Producer provides output in a concrete format.
 type Producer interface {
@@ -18,20 +40,305 @@ type Producer interface {
 	HTML(w io.Writer, name string) error
 }
 
+// ContextProducer is an optional interface implemented by Producers that
+// are able to stop producing output once the specified context is done.
+type ContextProducer interface {
+	// HTMLContext acts as HTML but respects cancellation of ctx.
+	HTMLContext(ctx context.Context, w io.Writer, name string) error
+}
+
+// StatusError is an error that Producers may return to make ServeMux
+// respond with a specific status code and message, e.g. 422 for a corrupt
+// file. It may be wrapped by other errors.
+type StatusError struct {
+	// Code is the HTTP status code of the response. Codes which aren't
+	// client or server errors are responded as 500 Internal Server Error.
+	Code int
+	// Message is written to the response body.
+	Message string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// LimitProducer is an optional interface implemented by Producers that
+// are able to output only the first rows of data. ServeMux uses it to
+// honor limit query parameter.
+type LimitProducer interface {
+	// HTMLLimitContext acts as HTMLContext but outputs at most limit
+	// rows. Zero limit means no limit.
+	HTMLLimitContext(ctx context.Context, w io.Writer, name string, limit int) error
+}
+
+// LayoutProducer is an optional interface implemented by Producers that
+// are able to tell how they interpret data. ServeMux uses it to respond
+// to debug=layout query parameter.
+type LayoutProducer interface {
+	// Layout returns the layout detected in the data with a given name.
+	// The layout is encoded to JSON. An error wrapping
+	// errors.ErrUnsupported means the layout is unknown.
+	Layout(name string) (interface{}, error)
+}
+
+// ContentTypeProducer is an optional interface implemented by Producers
+// which output isn't HTML. Content-Type of output of other Producers is
+// ContentTypeHTML.
+type ContentTypeProducer interface {
+	// ContentType returns the media type of output, e.g. ContentTypeCSV.
+	ContentType() string
+}
+
+// BufferedProducer is an optional interface implemented by Producers
+// which output is fine to keep in memory entirely, e.g. exports of
+// moderate size. ServeMux buffers such output, so that range requests
+// are supported for it and Content-Length is set.
+type BufferedProducer interface {
+	// Buffered reports whether output is fine to buffer.
+	Buffered() bool
+}
+
+// RawProducer is an optional interface implemented by Producers that are
+// able to show data as is. ServeMux uses it to respond to debug=raw query
+// parameter.
+type RawProducer interface {
+	// Raw writes the specified number of first lines of the data with
+	// a given name as is. An error wrapping errors.ErrUnsupported means
+	// the data can't be shown as is.
+	Raw(w io.Writer, name string, lines int) error
+}
+
+// ModTimeProducer is an optional interface implemented by Producers that
+// know when the data behind their output was modified last time.
+type ModTimeProducer interface {
+	// ModTime returns modification time of the data with a given name.
+	// If the time is unknown, false is returned.
+	ModTime(name string) (time.Time, bool)
+}
+
+// ProbeProducer is an optional interface implemented by Producers that
+// are able to check whether their data is reachable. ServeMux uses it to
+// respond at the readiness path.
+type ProbeProducer interface {
+	// Probe returns an error if no data can be produced at all, e.g.
+	// because a data directory is missing. An error wrapping
+	// errors.ErrUnsupported means the Producer can't be probed.
+	Probe() error
+}
+
 // ServeMux maps producers to HTTP requests by implementing http.Handler.
 // Producer is matched by the first segment of URL following the baseURL.
+// If ServeMux is mounted under a prefix of a parent handler, baseURL must
+// include the prefix unless the parent strips it, e.g. by http.StripPrefix.
 type ServeMux struct {
 	baseURL   string
 	producers map[string]Producer
-	mu        sync.Mutex
+	mu        sync.RWMutex
+	metrics   *metrics
+	logger    *slog.Logger
+	origins   []string
+	active    sync.WaitGroup
+	closing   bool
+	health    string
+	ready     string
+	probe     time.Duration
+	timeout   time.Duration
+	timeouts  map[string]time.Duration
+	slow      time.Duration
+	limiters  map[string]*rateLimiter
+	sem       chan struct{}
+	debug     bool
+	maxName   int
+	retry     time.Duration
 }
 
+// defaultMaxNameLength is the maximum length of names
+// in bytes unless another limit is specified.
+const defaultMaxNameLength = 255
+
+// defaultProbeTimeout is the time given to probes
+// unless another timeout is specified.
+const defaultProbeTimeout = 5 * time.Second
+
 // NewServeMux creates and initializes a new instance of ServeMux.
+// A trailing slash is appended to baseURL if it's missing.
 func NewServeMux(baseURL string) *ServeMux {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
 	return &ServeMux{
 		baseURL:   baseURL,
 		producers: make(map[string]Producer),
+		maxName:   defaultMaxNameLength,
+		probe:     defaultProbeTimeout,
+	}
+}
+
+// NewServeMuxWithMetrics creates and initializes a new instance of ServeMux
+// that records Prometheus metrics for every Producer invocation. Collectors
+// are registered with the specified Registerer.
+func NewServeMuxWithMetrics(baseURL string, reg prometheus.Registerer) (*ServeMux, error) {
+	m, err := newMetrics(reg)
+	if err != nil {
+		return nil, err
 	}
+	mux := NewServeMux(baseURL)
+	mux.metrics = m
+	return mux, nil
+}
+
+// NewServeMuxWithCORS creates and initializes a new instance of ServeMux
+// that allows cross-origin GET requests from the specified origins.
+// Use "*" to allow requests from any origin.
+func NewServeMuxWithCORS(baseURL string, allowedOrigins []string) *ServeMux {
+	mux := NewServeMux(baseURL)
+	mux.origins = allowedOrigins
+	return mux
+}
+
+// NewServeMuxWithConcurrency creates and initializes a new instance of
+// ServeMux that invokes at most max Producers at the same time. Requests
+// that exceed the limit are responded with 503 Service Unavailable.
+// Debug requests, e.g. debug=layout, count against the limit as well.
+func NewServeMuxWithConcurrency(baseURL string, max int) *ServeMux {
+	if max <= 0 {
+		panic(fmt.Sprintf("Invalid concurrency limit %d", max))
+	}
+	mux := NewServeMux(baseURL)
+	mux.sem = make(chan struct{}, max)
+	return mux
+}
+
+// NewServeMuxWithMaxNameLength creates and initializes a new instance of
+// ServeMux that accepts names of at most max bytes. Requests with longer
+// names are responded with 414 Request URI Too Long without invoking
+// Producers. By default the limit is 255 bytes.
+func NewServeMuxWithMaxNameLength(baseURL string, max int) *ServeMux {
+	if max <= 0 {
+		panic(fmt.Sprintf("Invalid name length limit %d", max))
+	}
+	mux := NewServeMux(baseURL)
+	mux.maxName = max
+	return mux
+}
+
+// SetLogger sets the logger used to report errors and panics of Producers.
+// If logger is nil, slog.Default() is used which is also the default.
+func (mux *ServeMux) SetLogger(logger *slog.Logger) {
+	mux.logger = logger
+}
+
+// SetDebug turns on verbose error responses that help to debug clients,
+// e.g. a request for an unknown Producer is responded with the list of
+// registered keys. It's off by default, so responses reveal nothing.
+func (mux *ServeMux) SetDebug(debug bool) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.debug = debug
+}
+
+// SetHealthPath sets the URL path at which ServeMux responds 200 OK with
+// body "ok" regardless of registered Producers, e.g. "/healthz". The path
+// must match exactly, so it doesn't shadow a Producer with the same key.
+// Empty path disables the health check which is the default. Once
+// shutdown is started, the health check responds like any other request.
+func (mux *ServeMux) SetHealthPath(path string) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.health = path
+}
+
+// SetReadyPath sets the URL path at which ServeMux responds with health
+// of every registered ProbeProducer, e.g. "/readyz". The response is a JSON
+// object that maps keys of Producers to "ok" or "unavailable". In debug
+// mode errors of failed probes are responded instead of "unavailable".
+// The status is 200 OK if all probes succeed, otherwise it's 503 Service
+// Unavailable. Producers that can't be probed aren't listed. The path must
+// match exactly. Empty path disables the check which is the default.
+// Probes run in parallel, see SetProbeTimeout.
+func (mux *ServeMux) SetReadyPath(path string) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.ready = path
+}
+
+// SetProbeTimeout sets the time given to probes at the readiness path.
+// Probes that don't finish in time, e.g. because a network mount hangs,
+// are responded as unavailable and left running on their own. Zero or
+// negative timeout means the default of 5 seconds.
+func (mux *ServeMux) SetProbeTimeout(timeout time.Duration) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	mux.probe = timeout
+}
+
+// SetSlowThreshold makes ServeMux log a warning with prefix [SLOW] for
+// every Producer invocation that lasts longer than threshold, e.g. to
+// find files that are slow to read. The duration covers the whole output,
+// including streaming to the client. Zero threshold turns logging off
+// which is the default.
+func (mux *ServeMux) SetSlowThreshold(threshold time.Duration) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.slow = threshold
+}
+
+// SetTimeout sets the time given to every Producer to handle a request.
+// If the timeout is exceeded before anything is written, the request
+// is responded with 504 Gateway Timeout, otherwise the response is
+// aborted. Only a ContextProducer can be stopped in time, output of
+// other Producers finished late is responded as is. Zero timeout means
+// no timeout which is the default.
+func (mux *ServeMux) SetTimeout(timeout time.Duration) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.timeout = timeout
+}
+
+// SetRetryAfter sets the delay suggested to clients by Retry-After header
+// once they are responded with 503 Service Unavailable because of shutdown
+// or the concurrency limit. The delay is rounded up to whole seconds. Zero
+// delay means no header which is the default.
+func (mux *ServeMux) SetRetryAfter(delay time.Duration) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.retry = delay
+}
+
+// SetProducerTimeout overrides the timeout set by SetTimeout for
+// a Producer with the specified key.
+func (mux *ServeMux) SetProducerTimeout(key string, timeout time.Duration) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if mux.timeouts == nil {
+		mux.timeouts = make(map[string]time.Duration)
+	}
+	mux.timeouts[key] = timeout
+}
+
+// SetProducerRateLimit limits requests to a Producer with the specified key
+// to rate requests per second on average with bursts of at most burst
+// requests, e.g. to protect an expensive storage. Requests over the limit
+// are responded with 429 Too Many Requests and Retry-After header telling
+// when the next request is allowed. Burst less than one is one. Zero or
+// negative rate removes the limit which is the default.
+func (mux *ServeMux) SetProducerRateLimit(key string, rate float64, burst int) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if rate <= 0 {
+		delete(mux.limiters, key)
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if mux.limiters == nil {
+		mux.limiters = make(map[string]*rateLimiter)
+	}
+	mux.limiters[key] = newRateLimiter(rate, burst)
 }
 
 // AddProducer adds the specified Producer and maps it to the specified
@@ -56,50 +363,546 @@ func (mux *ServeMux) AddProducer(key string, p Producer) error {
 	return nil
 }
 
+// RemoveProducer removes the Producer mapped to the specified key.
+// Requests that are already handled by the Producer aren't affected.
+func (mux *ServeMux) RemoveProducer(key string) error {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if _, exists := mux.producers[key]; !exists {
+		return fmt.Errorf("Producer with key %s is not registered", key)
+	}
+	delete(mux.producers, key)
+	return nil
+}
+
+// ListProducers returns sorted keys of registered Producers.
+func (mux *ServeMux) ListProducers() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	keys := make([]string, 0, len(mux.producers))
+	for key := range mux.producers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Shutdown stops accepting new requests and waits until active requests
+// are finished. Requests that come after Shutdown is called are responded
+// with 503 Service Unavailable. If ctx is done before active requests are
+// finished, the context's error is returned.
+func (mux *ServeMux) Shutdown(ctx context.Context) error {
+	mux.mu.Lock()
+	mux.closing = true
+	mux.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		mux.active.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ServeHTTP handles HTTP requests by transferring them to registered Producers.
 func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux.mu.RLock()
+	if mux.closing {
+		retry := mux.retry
+		mux.mu.RUnlock()
+		unavailable(w, "Server is shutting down", retry)
+		return
+	}
+	health := mux.health
+	ready := mux.ready
+	mux.active.Add(1)
+	mux.mu.RUnlock()
+	defer mux.active.Done()
+
+	if health != "" && r.URL.Path == health {
+		io.WriteString(w, "ok")
+		return
+	}
+	if ready != "" && r.URL.Path == ready {
+		mux.serveReady(w, r)
+		return
+	}
+
+	var pk, name string
 	defer func() {
-		if r := recover(); r != nil {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
 			http.Error(w, "Unexpected error occured", http.StatusInternalServerError)
-			mux.log("panic", r)
+			mux.log(r, slog.LevelError, "panic", pk, name, v)
 		}
 	}()
 
-	rel := r.URL.Path[len(mux.baseURL):]
-	segs := strings.Split(rel, "/")
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, mux.baseURL) {
+		http.NotFound(w, r)
+		return
+	}
+	segs := strings.Split(path[len(mux.baseURL):], "/")
+	if len(segs) == 3 && segs[2] == "" {
+		// A single trailing slash is tolerated, so /key/name/ is routed as /key/name.
+		segs = segs[:2]
+	}
 	if len(segs) != 2 {
 		http.NotFound(w, r)
 		return
 	}
 
-	pk := segs[0]
-	name := segs[1]
+	var err error
+	if pk, err = url.PathUnescape(segs[0]); err != nil {
+		http.Error(w, "Malformed URL", http.StatusBadRequest)
+		return
+	}
+	if name, err = url.PathUnescape(segs[1]); err != nil || !isValidName(name) {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
+		return
+	}
+	if len(name) > mux.maxName {
+		http.Error(w, "Name is too long", http.StatusRequestURITooLong)
+		return
+	}
 
-	mux.mu.Lock()
+	mux.mu.RLock()
 	p, ok := mux.producers[pk]
-	mux.mu.Unlock()
+	timeout, custom := mux.timeouts[pk]
+	if !custom {
+		timeout = mux.timeout
+	}
+	debug := mux.debug
+	retry := mux.retry
+	slow := mux.slow
+	limiter := mux.limiters[pk]
+	mux.mu.RUnlock()
 
 	if !ok {
-		http.Error(w, fmt.Sprintf("%s is not supported", pk), http.StatusNotImplemented)
+		msg := fmt.Sprintf("%s is not supported", pk)
+		if debug {
+			msg = fmt.Sprintf("%s %s: %s, supported keys: %s",
+				r.Method, r.URL.Path, msg, strings.Join(mux.ListProducers(), ", "))
+		}
+		http.Error(w, msg, http.StatusNotImplemented)
+		return
+	}
+	mux.setCORSHeaders(w, r)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", allowedMethods)
 		http.Error(w, fmt.Sprintf("%s is not allowed", r.Method), http.StatusMethodNotAllowed)
 		return
 	}
+	if limiter != nil {
+		if wait := limiter.reserve(time.Now()); wait > 0 {
+			seconds := int64(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	var limit int
+	if limit, err = parseLimit(r.URL.Query().Get("limit")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// debug requests read files as well, so they are limited too.
+	if mux.sem != nil {
+		select {
+		case mux.sem <- struct{}{}:
+			// released by defer, so panics release it as well.
+			defer func() { <-mux.sem }()
+		default:
+			unavailable(w, "Too many requests in progress", retry)
+			return
+		}
+	}
+
+	switch debug := r.URL.Query().Get("debug"); debug {
+	case "":
+	case "layout":
+		mux.serveLayout(w, r, p, pk, name)
+		return
+	case "raw":
+		mux.serveRaw(w, r, p, pk, name)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("Unknown debug %q", debug), http.StatusBadRequest)
+		return
+	}
+
+	// outcome is changed right after HTML returns, so it
+	// remains untouched if the Producer panics.
+	outcome := outcomePanic
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		mux.metrics.observe(pk, outcome, elapsed)
+		if slow > 0 && elapsed > slow {
+			mux.log(r, slog.LevelWarn, "slow", pk, name, elapsed)
+		}
+	}()
+
+	var modTime time.Time
+	if mp, ok := p.(ModTimeProducer); ok {
+		var known bool
+		if modTime, known = mp.ModTime(name); known {
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		}
+	}
+	// Content-Type is set explicitly since sniffing
+	// may misdetect the first bytes of streamed output.
+	contentType := ContentTypeHTML
+	if cp, ok := p.(ContentTypeProducer); ok {
+		contentType = cp.ContentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	ctx := r.Context()
+	var out io.Writer = w
+	tw := &trackingWriter{ResponseWriter: w}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		out = tw
+	}
+	var buf *bytes.Buffer
+	if bp, ok := p.(BufferedProducer); ok && bp.Buffered() {
+		buf = &bytes.Buffer{}
+		out = buf
+	} else {
+		// generated output can't be served partially.
+		w.Header().Set("Accept-Ranges", "none")
+	}
+
+	err = produceHTML(ctx, p, out, name, limit)
+	if timeout > 0 && timedOut(ctx, p, err, limit) {
+		outcome = outcomeTimeout
+		mux.log(r, slog.LevelWarn, "timeout", pk, name, timeout)
+		if !tw.written {
+			http.Error(w, "Producer timed out", http.StatusGatewayTimeout)
+			return
+		}
+		// headers are already sent, so the only way
+		// to report a failure is to abort the response.
+		panic(http.ErrAbortHandler)
+	}
 
-	if err := p.HTML(w, name); err != nil {
-		if os.IsNotExist(err) {
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			outcome = outcomeNotFound
 			http.NotFound(w, r)
 			return
 		}
+		var se StatusError
+		if errors.As(err, &se) {
+			code := se.Code
+			if code < http.StatusBadRequest || code > 599 {
+				// WriteHeader panics on codes out of 100-999,
+				// and the rest don't tell about a failure.
+				code = http.StatusInternalServerError
+			}
+			outcome = outcomeError
+			if code == http.StatusNotFound {
+				outcome = outcomeNotFound
+			}
+			http.Error(w, se.Message, code)
+			if code >= http.StatusInternalServerError {
+				mux.log(r, slog.LevelError, "error", pk, name, err)
+			}
+			return
+		}
+		outcome = outcomeError
 		http.Error(w, "Can't produce output", http.StatusInternalServerError)
-		mux.log("error", err)
+		mux.log(r, slog.LevelError, "error", pk, name, err)
+		return
+	}
+	outcome = outcomeOK
+	if buf != nil {
+		http.ServeContent(w, r, name, modTime, bytes.NewReader(buf.Bytes()))
+	}
+}
+
+// serveReady responds with health of every ProbeProducer.
+func (mux *ServeMux) serveReady(w http.ResponseWriter, r *http.Request) {
+	mux.mu.RLock()
+	probes := make(map[string]ProbeProducer, len(mux.producers))
+	for pk, p := range mux.producers {
+		if pp, ok := p.(ProbeProducer); ok {
+			probes[pk] = pp
+		}
+	}
+	debug := mux.debug
+	timeout := mux.probe
+	mux.mu.RUnlock()
+
+	type probeResult struct {
+		pk  string
+		err error
 	}
+	// results is buffered, so that probes finished
+	// after the timeout don't block forever.
+	results := make(chan probeResult, len(probes))
+	for pk, pp := range probes {
+		go func(pk string, pp ProbeProducer) {
+			results <- probeResult{pk: pk, err: pp.Probe()}
+		}(pk, pp)
+	}
+
+	status := http.StatusOK
+	health := make(map[string]string, len(probes))
+	report := func(pk string, err error) {
+		switch {
+		case err == nil:
+			health[pk] = "ok"
+		case errors.Is(err, errors.ErrUnsupported):
+		default:
+			mux.log(r, slog.LevelWarn, "probe", pk, "", err)
+			status = http.StatusServiceUnavailable
+			health[pk] = "unavailable"
+			if debug {
+				health[pk] = err.Error()
+			}
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for len(probes) > 0 {
+		select {
+		case res := <-results:
+			delete(probes, res.pk)
+			report(res.pk, res.err)
+		case <-timer.C:
+			for pk := range probes {
+				delete(probes, pk)
+				report(pk, fmt.Errorf("Probe timed out after %s", timeout))
+			}
+		}
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
 }
 
-func (mux *ServeMux) log(prefix string, v ...interface{}) {
-	prefix = fmt.Sprintf("[%s]", strings.ToUpper(prefix))
-	v = append([]interface{}{prefix}, v...)
-	log.Println(v...)
+// serveLayout responds with the layout of the data with the specified
+// name encoded to JSON. Rows are not produced.
+func (mux *ServeMux) serveLayout(w http.ResponseWriter, r *http.Request, p Producer, pk, name string) {
+	lp, ok := p.(LayoutProducer)
+	if !ok {
+		http.Error(w, "Layout is not supported", http.StatusNotImplemented)
+		return
+	}
+	lt, err := lp.Layout(name)
+	if err != nil {
+		mux.debugError(w, r, "Layout", pk, name, err)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	json.NewEncoder(w).Encode(lt)
+}
+
+// defaultRawLines is the number of lines responded
+// to debug=raw if lines query parameter is missing.
+const defaultRawLines = 10
+
+// serveRaw responds with first lines of the data with the specified
+// name as is. The number of lines is taken from lines query parameter.
+func (mux *ServeMux) serveRaw(w http.ResponseWriter, r *http.Request, p Producer, pk, name string) {
+	lines := defaultRawLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		var err error
+		if lines, err = strconv.Atoi(v); err != nil || lines <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid lines %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	rp, ok := p.(RawProducer)
+	if !ok {
+		http.Error(w, "Raw content is not supported", http.StatusNotImplemented)
+		return
+	}
+	// lines are buffered, so that a failure is
+	// reported instead of a truncated content.
+	var buf bytes.Buffer
+	if err := rp.Raw(&buf, name, lines); err != nil {
+		mux.debugError(w, r, "Raw content", pk, name, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// debugError responds with the error of getting the specified
+// debug information, e.g. "Layout", of the data with the specified name.
+func (mux *ServeMux) debugError(w http.ResponseWriter, r *http.Request, what, pk, name string, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, errors.ErrUnsupported):
+		http.Error(w, what+" is not supported", http.StatusNotImplemented)
+	default:
+		http.Error(w, "Can't read "+strings.ToLower(what), http.StatusInternalServerError)
+		mux.log(r, slog.LevelError, "error", pk, name, err)
+	}
+}
+
+// unavailable responds with 503 Service Unavailable and the specified
+// message. Retry-After header is set if the retry delay is positive.
+func unavailable(w http.ResponseWriter, msg string, retry time.Duration) {
+	if retry > 0 {
+		seconds := int64(math.Ceil(retry.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+	http.Error(w, msg, http.StatusServiceUnavailable)
+}
+
+// parseLimit parses the value of limit query parameter. Missing
+// limit is returned as zero, i.e. no limit.
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("Invalid limit %q", v)
+	}
+	return limit, nil
+}
+
+// produceHTML calls HTMLLimitContext if the limit is set and the Producer
+// is a LimitProducer. Otherwise HTMLContext is called if the Producer is
+// a ContextProducer, or a plain HTML is used. Producers unable to limit
+// their output ignore the limit.
+func produceHTML(ctx context.Context, p Producer, w io.Writer, name string, limit int) error {
+	if lp, ok := p.(LimitProducer); ok && limit > 0 {
+		return lp.HTMLLimitContext(ctx, w, name, limit)
+	}
+	if cp, ok := p.(ContextProducer); ok {
+		return cp.HTMLContext(ctx, w, name)
+	}
+	return p.HTML(w, name)
+}
+
+// timedOut reports whether the output produced by p is cut short
+// by the deadline of ctx. A plain Producer isn't aware of ctx, so its
+// output is complete unless it fails with the context error. A Producer
+// aware of ctx may stop silently once ctx is done, so its output is
+// considered incomplete then.
+func timedOut(ctx context.Context, p Producer, err error, limit int) bool {
+	if ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	if err != nil {
+		return errors.Is(err, context.DeadlineExceeded)
+	}
+	if _, ok := p.(LimitProducer); ok && limit > 0 {
+		return true
+	}
+	_, ok := p.(ContextProducer)
+	return ok
+}
+
+// trackingWriter remembers whether anything is written to the response.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackingWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush allows Producers to stream output through trackingWriter.
+func (w *trackingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// setCORSHeaders allows cross-origin request if its origin is allowed.
+// Preflight requests are additionally provided with allowed methods.
+func (mux *ServeMux) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	if len(mux.origins) == 0 {
+		return
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	allowed := ""
+	for _, o := range mux.origins {
+		if o == "*" {
+			allowed = "*"
+			break
+		}
+		if o == origin {
+			allowed = origin
+		}
+	}
+	if allowed == "" {
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", allowed)
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		h.Set("Access-Control-Allow-Methods", allowedMethods)
+	}
+}
+
+// isValidName reports whether the decoded name can be passed to a Producer.
+// Names must not be empty, contain path separators or control characters.
+func isValidName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// log writes v with a human-readable prefix along with request
+// attributes that allow to trace the failure.
+func (mux *ServeMux) log(r *http.Request, level slog.Level, prefix string, pk, name string, v interface{}) {
+	logger := mux.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	msg := fmt.Sprintf("[%s] %v", strings.ToUpper(prefix), v)
+	logger.LogAttrs(r.Context(), level, msg,
+		slog.String("producer", pk),
+		slog.String("name", name),
+		slog.String("method", r.Method),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
 }
@@ -2,14 +2,26 @@ package producers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/csv"
+	"registry-sample/readers/loader"
+	"registry-sample/readers/mon"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -59,7 +71,7 @@ func TestAddProducer_AddKeyTwice_ErrorReturned(t *testing.T) {
 }
 
 func TestServeHTTP_WrongURL_StatusNotFoundWritten(t *testing.T) {
-	wrongURLs := []string{"/key", "/", "/key/page1/page2"}
+	wrongURLs := []string{"/key", "/", "/key/page1/page2", "/key/page1/page2/"}
 	for _, url := range wrongURLs {
 		r := httptest.NewRequest(http.MethodGet, url, nil)
 		w := httptest.NewRecorder()
@@ -73,6 +85,62 @@ func TestServeHTTP_WrongURL_StatusNotFoundWritten(t *testing.T) {
 	}
 }
 
+func TestServeHTTP_MountedUnderPrefix_ProducerInvoked(t *testing.T) {
+	p := testProducer{}
+	mux := NewServeMux("/api")
+	mux.AddProducer("key", &p)
+	parent := http.NewServeMux()
+	parent.Handle("/api/", mux)
+
+	w := httptest.NewRecorder()
+	parent.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/key/name", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "name", p.htmlName)
+}
+
+func TestServeHTTP_MountedWithStripPrefix_ProducerInvoked(t *testing.T) {
+	p := testProducer{}
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	parent := http.NewServeMux()
+	parent.Handle("/api/", http.StripPrefix("/api", mux))
+
+	w := httptest.NewRecorder()
+	parent.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/key/a%20name", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a name", p.htmlName)
+}
+
+func TestServeHTTP_PathOutsideBaseURL_StatusNotFoundWritten(t *testing.T) {
+	for _, url := range []string{"/", "/a", "/ap", "/other/key/name"} {
+		p := testProducer{}
+		mux := NewServeMux("/api/")
+		mux.AddProducer("key", &p)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code, "url: %s", url)
+		assert.Nil(t, p.htmlWriter, "url: %s", url)
+	}
+}
+
+func TestServeHTTP_PathShorterThanBaseURL_StatusNotFoundWithoutPanic(t *testing.T) {
+	logBuf := bytes.Buffer{}
+	mux := NewServeMux("/app/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(&logBuf, nil)))
+	mux.AddProducer("key", &testProducer{})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "404 page not found\n", w.Body.String())
+	assert.Empty(t, logBuf.String())
+}
+
 func TestServeHTTP_UnmappedKeyInURL_StatusNotImplementedWritten(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/key2/name", nil)
 	w := httptest.NewRecorder()
@@ -131,6 +199,20 @@ func TestServeHTTP_ValidRequest_ProducerInvoked(t *testing.T) {
 	assert.Equal(t, w, p2.htmlWriter)
 }
 
+func TestServeHTTP_TrailingSlash_RoutedAsWithout(t *testing.T) {
+	for _, url := range []string{"/key/name", "/key/name/"} {
+		p := testProducer{}
+		mux := NewServeMux("/")
+		mux.AddProducer("key", &p)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code, "url: %s", url)
+		assert.Equal(t, "name", p.htmlName, "url: %s", url)
+	}
+}
+
 func TestServeHTTP_ProducerErrorErrNotExist_StatusNotFoundWritten(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
 	w := httptest.NewRecorder()
@@ -197,3 +279,1368 @@ func TestServeHTTP_ProducerPaniced_PanicWrittenToLog(t *testing.T) {
 
 	assert.Contains(t, logBuf.String(), "[PANIC] it-happens")
 }
+
+func TestServeHTTP_ProducerErrorWithLogger_StructuredErrorLogged(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	w := httptest.NewRecorder()
+	p := testProducer{err: errors.New("sad-but-true")}
+	logBuf := bytes.Buffer{}
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(&logBuf, nil)))
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	var entry map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(logBuf.Bytes(), &entry)) {
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "[ERROR] sad-but-true", entry["msg"])
+		assert.Equal(t, "key", entry["producer"])
+		assert.Equal(t, "name", entry["name"])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Equal(t, "10.0.0.1:1234", entry["remote_addr"])
+	}
+}
+
+func TestServeHTTP_ProducerPanicedWithLogger_StructuredPanicLogged(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+	p := testProducer{panic: "it-happens"}
+	logBuf := bytes.Buffer{}
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(&logBuf, nil)))
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	var entry map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(logBuf.Bytes(), &entry)) {
+		assert.Equal(t, "[PANIC] it-happens", entry["msg"])
+		assert.Equal(t, "key", entry["producer"])
+		assert.Equal(t, "name", entry["name"])
+	}
+}
+
+func TestServeHTTP_InvalidName_StatusBadRequestWritten(t *testing.T) {
+	invalidURLs := []string{"/key/", "/key/%2e%2e", "/key/.", "/key/a%2Fb", "/key/a%5Cb", "/key/a%00b", "/key/a%0Ab"}
+	for _, url := range invalidURLs {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		p := testProducer{}
+
+		mux := NewServeMux("/")
+		mux.AddProducer("key", &p)
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "url: %s", url)
+		assert.Equal(t, "Invalid name\n", w.Body.String(), "url: %s", url)
+		assert.Nil(t, p.htmlWriter, "url: %s", url)
+	}
+}
+
+func TestServeHTTP_NameAtDefaultLimit_ProducerInvoked(t *testing.T) {
+	name := strings.Repeat("a", 255)
+	r := httptest.NewRequest(http.MethodGet, "/key/"+name, nil)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, name, p.htmlName)
+}
+
+func TestServeHTTP_NameOverDefaultLimit_StatusRequestURITooLongWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/"+strings.Repeat("a", 256), nil)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestURITooLong, w.Code)
+	assert.Equal(t, "Name is too long\n", w.Body.String())
+	assert.Nil(t, p.htmlWriter)
+}
+
+func TestServeHTTP_MaxNameLength_NamesAroundLimitHandled(t *testing.T) {
+	testCases := []struct {
+		name string
+		code int
+	}{
+		{name: "abcdefghi", code: http.StatusOK},
+		{name: "abcdefghij", code: http.StatusOK},
+		{name: "abcdefghijk", code: http.StatusRequestURITooLong},
+		{name: "%C3%A9bcdefghij", code: http.StatusRequestURITooLong},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/key/"+tc.name, nil)
+		w := httptest.NewRecorder()
+
+		mux := NewServeMuxWithMaxNameLength("/", 10)
+		mux.AddProducer("key", &testProducer{})
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, tc.code, w.Code, "name: %s", tc.name)
+	}
+}
+
+func TestNewServeMuxWithMaxNameLength_InvalidMax_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewServeMuxWithMaxNameLength("/", 0) })
+}
+
+func TestServeHTTP_EscapedName_DecodedNamePassed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/spread%20sheet", nil)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "spread sheet", p.htmlName)
+}
+
+func TestServeHTTP_OptionsMethod_StatusNoContentWithAllowWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/key/name", nil)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Allow"))
+	assert.Nil(t, p.htmlWriter)
+}
+
+func TestServeHTTP_WrongMethod_AllowHeaderWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &testProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestServeHTTP_OptionsMethodUnmappedKey_StatusNotImplementedWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/key2/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key1", &testProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Empty(t, w.Header().Get("Allow"))
+}
+
+func TestServeHTTP_CORSAllowedOrigin_AllowOriginWritten(t *testing.T) {
+	testCases := []struct {
+		origins []string
+		want    string
+	}{
+		{origins: []string{"http://a.example", "http://b.example"}, want: "http://b.example"},
+		{origins: []string{"*"}, want: "*"},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+		r.Header.Set("Origin", "http://b.example")
+		w := httptest.NewRecorder()
+
+		mux := NewServeMuxWithCORS("/", tc.origins)
+		mux.AddProducer("key", &testProducer{})
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tc.want, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestServeHTTP_CORSDisallowedOrigin_NoAllowOriginWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMuxWithCORS("/", []string{"http://a.example"})
+	mux.AddProducer("key", &testProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestServeHTTP_CORSPreflight_AllowMethodsWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/key/name", nil)
+	r.Header.Set("Origin", "http://a.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMuxWithCORS("/", []string{"http://a.example"})
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "http://a.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Nil(t, p.htmlWriter)
+}
+
+func TestServeHTTP_WithoutCORS_NoAllowOriginWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	r.Header.Set("Origin", "http://a.example")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &testProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+// slowProducer blocks in HTML until released.
+type slowProducer struct {
+	started  chan struct{}
+	release  chan struct{}
+	finished chan struct{}
+}
+
+func newSlowProducer() *slowProducer {
+	return &slowProducer{
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+func (p *slowProducer) HTML(w io.Writer, name string) error {
+	close(p.started)
+	<-p.release
+	close(p.finished)
+	return nil
+}
+
+func TestShutdown_ActiveRequest_NewRequestsRejectedAndActiveCompleted(t *testing.T) {
+	p := newSlowProducer()
+	mux := NewServeMux("/")
+	mux.AddProducer("key", p)
+
+	w1 := httptest.NewRecorder()
+	go mux.ServeHTTP(w1, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+	<-p.started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- mux.Shutdown(context.Background())
+	}()
+
+	// wait until Shutdown marks mux as closing
+	for {
+		w2 := httptest.NewRecorder()
+		mux.ServeHTTP(w2, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+		if w2.Code == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned before active request is finished")
+	default:
+	}
+
+	close(p.release)
+	assert.NoError(t, <-shutdown)
+	select {
+	case <-p.finished:
+	default:
+		t.Fatal("active request is not finished")
+	}
+}
+
+func TestShutdown_ContextExpired_ContextErrorReturned(t *testing.T) {
+	p := newSlowProducer()
+	defer close(p.release)
+	mux := NewServeMux("/")
+	mux.AddProducer("key", p)
+
+	go mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/key/name", nil))
+	<-p.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := mux.Shutdown(ctx)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestShutdown_NoActiveRequests_NilReturned(t *testing.T) {
+	mux := NewServeMux("/")
+	err := mux.Shutdown(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestServeHTTP_ProducerJoinedErrorErrNotExist_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+	p := testProducer{err: errors.Join(os.ErrNotExist, errors.New("template failed"))}
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServeHTTP_HealthPath_StatusOKWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetHealthPath("/healthz")
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestServeHTTP_HealthPathAndProducerKey_ProducerNotShadowed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/healthz/name", nil)
+	w := httptest.NewRecorder()
+	p := testProducer{}
+
+	mux := NewServeMux("/")
+	mux.SetHealthPath("/healthz")
+	mux.AddProducer("healthz", &p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "name", p.htmlName)
+}
+
+func TestServeHTTP_NoHealthPath_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// contextProducer writes the specified content and waits until ctx is done.
+type contextProducer struct {
+	content string
+}
+
+func (p *contextProducer) HTML(w io.Writer, name string) error {
+	return p.HTMLContext(context.Background(), w, name)
+}
+
+func (p *contextProducer) HTMLContext(ctx context.Context, w io.Writer, name string) error {
+	if p.content != "" {
+		io.WriteString(w, p.content)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestServeHTTP_TimeoutBeforeWrite_StatusGatewayTimeoutWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetTimeout(10 * time.Millisecond)
+	mux.AddProducer("key", &contextProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "Producer timed out\n", w.Body.String())
+}
+
+func TestServeHTTP_TimeoutAfterWrite_ResponseAborted(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetTimeout(10 * time.Millisecond)
+	mux.AddProducer("key", &contextProducer{content: "<html>"})
+
+	func() {
+		defer func() {
+			assert.Equal(t, http.ErrAbortHandler, recover())
+		}()
+		mux.ServeHTTP(w, r)
+	}()
+	assert.Equal(t, "<html>", w.Body.String())
+}
+
+// lateProducer writes the specified content after the specified delay
+// without respecting a deadline.
+type lateProducer struct {
+	content  string
+	delay    time.Duration
+	buffered bool
+	err      error
+}
+
+func (p lateProducer) HTML(w io.Writer, name string) error {
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := io.WriteString(w, p.content)
+	return err
+}
+
+func (p lateProducer) Buffered() bool {
+	return p.buffered
+}
+
+func TestServeHTTP_PlainProducerSucceedsAfterTimeout_OutputServed(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+		w := httptest.NewRecorder()
+
+		mux := NewServeMux("/")
+		mux.SetTimeout(10 * time.Millisecond)
+		mux.AddProducer("key", lateProducer{content: "<html>", delay: 30 * time.Millisecond, buffered: buffered})
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code, "buffered: %v", buffered)
+		assert.Equal(t, "<html>", w.Body.String(), "buffered: %v", buffered)
+	}
+}
+
+func TestServeHTTP_PlainProducerFailsAfterTimeout_ErrorServed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetTimeout(10 * time.Millisecond)
+	mux.AddProducer("key", lateProducer{delay: 30 * time.Millisecond, err: os.ErrNotExist})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServeHTTP_ProducerTimeoutOverride_OverrideUsed(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.SetTimeout(time.Hour)
+	mux.SetProducerTimeout("slow", 10*time.Millisecond)
+	mux.AddProducer("slow", &contextProducer{})
+	mux.AddProducer("key", &testProducer{})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow/name", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRemoveProducer_RegisteredKey_ProducerRemoved(t *testing.T) {
+	mux := NewServeMux("/")
+	_ = mux.AddProducer("key1", &testProducer{})
+	err := mux.RemoveProducer("key1")
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key1/name", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestRemoveProducer_UnknownKey_ErrorReturned(t *testing.T) {
+	mux := NewServeMux("/")
+	err := mux.RemoveProducer("key1")
+	assert.EqualError(t, err, "Producer with key key1 is not registered")
+}
+
+func TestListProducers_SeveralProducers_SortedKeysReturned(t *testing.T) {
+	mux := NewServeMux("/")
+	_ = mux.AddProducer("mon", &testProducer{})
+	_ = mux.AddProducer("csv", &testProducer{})
+	assert.Equal(t, []string{"csv", "mon"}, mux.ListProducers())
+}
+
+// nopProducer writes nothing and is safe for concurrent use.
+type nopProducer struct{}
+
+func (nopProducer) HTML(w io.Writer, name string) error {
+	return nil
+}
+
+func TestServeHTTP_ConcurrentRequestsAndAdd_NoRace(t *testing.T) {
+	mux := NewServeMux("/")
+	_ = mux.AddProducer("key0", nopProducer{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key0/name", nil))
+				assert.Equal(t, http.StatusOK, w.Code)
+				mux.ListProducers()
+			}
+		}()
+	}
+	for i := 1; i <= 10; i++ {
+		assert.NoError(t, mux.AddProducer(fmt.Sprintf("key%d", i), nopProducer{}))
+	}
+	wg.Wait()
+	assert.Len(t, mux.ListProducers(), 11)
+}
+
+func BenchmarkServeHTTP_Parallel(b *testing.B) {
+	mux := NewServeMux("/")
+	_ = mux.AddProducer("key1", nopProducer{})
+	r := httptest.NewRequest(http.MethodGet, "/key1/name", nil)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+}
+
+type modTimeProducer struct {
+	nopProducer
+	modTime time.Time
+	known   bool
+}
+
+func (p modTimeProducer) ModTime(name string) (time.Time, bool) {
+	return p.modTime, p.known
+}
+
+func TestServeHTTP_ProducerModTimeKnown_LastModifiedWritten(t *testing.T) {
+	mux := NewServeMux("/")
+	modTime := time.Date(2017, 10, 8, 16, 25, 37, 0, time.FixedZone("", 3*60*60))
+	_ = mux.AddProducer("key1", modTimeProducer{modTime: modTime, known: true})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key1/name", nil))
+
+	assert.Equal(t, "Sun, 08 Oct 2017 13:25:37 GMT", w.Header().Get("Last-Modified"))
+}
+
+func TestServeHTTP_ProducerModTimeUnknown_NoLastModified(t *testing.T) {
+	mux := NewServeMux("/")
+	_ = mux.AddProducer("key1", modTimeProducer{})
+	_ = mux.AddProducer("key2", nopProducer{})
+
+	for _, url := range []string{"/key1/name", "/key2/name"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Last-Modified"))
+	}
+}
+
+func TestServeHTTP_ConcurrencyLimitReached_StatusServiceUnavailableWritten(t *testing.T) {
+	p1, p2 := newSlowProducer(), newSlowProducer()
+	mux := NewServeMuxWithConcurrency("/", 2)
+	mux.AddProducer("slow1", p1)
+	mux.AddProducer("slow2", p2)
+	mux.AddProducer("fast", nopProducer{})
+
+	done := make(chan struct{})
+	for _, key := range []string{"slow1", "slow2"} {
+		go func(key string) {
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+key+"/name", nil))
+			done <- struct{}{}
+		}(key)
+	}
+	<-p1.started
+	<-p2.started
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast/name", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(p1.release)
+	<-done
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast/name", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	close(p2.release)
+	<-done
+}
+
+func TestServeHTTP_ConcurrencyLimitReachedOnDebug_StatusServiceUnavailableWritten(t *testing.T) {
+	p := newSlowProducer()
+	mux := NewServeMuxWithConcurrency("/", 1)
+	mux.AddProducer("slow", p)
+	mux.AddProducer("layout", &layoutProducer{layout: []string{"Name"}})
+
+	done := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow/name", nil))
+		close(done)
+	}()
+	<-p.started
+
+	for _, debug := range []string{"layout", "raw"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/layout/name?debug="+debug, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "debug: %s", debug)
+	}
+
+	close(p.release)
+	<-done
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/layout/name?debug=layout", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServeHTTP_ConcurrencyLimitWithRetryAfter_RetryAfterHeaderWritten(t *testing.T) {
+	p := newSlowProducer()
+	mux := NewServeMuxWithConcurrency("/", 1)
+	mux.SetRetryAfter(1500 * time.Millisecond)
+	mux.AddProducer("slow", p)
+	mux.AddProducer("fast", nopProducer{})
+
+	done := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow/name", nil))
+		close(done)
+	}()
+	<-p.started
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast/name", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	close(p.release)
+	<-done
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast/name", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+func TestServeHTTP_ShutdownWithRetryAfter_RetryAfterHeaderWritten(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.SetRetryAfter(30 * time.Second)
+	mux.AddProducer("key", nopProducer{})
+	assert.NoError(t, mux.Shutdown(context.Background()))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+func TestServeHTTP_ShutdownWithoutRetryAfter_NoRetryAfterHeader(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key", nopProducer{})
+	assert.NoError(t, mux.Shutdown(context.Background()))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	_, ok := w.Header()["Retry-After"]
+	assert.False(t, ok)
+}
+
+func TestServeHTTP_ConcurrencyLimitAfterFailures_SlotsReleased(t *testing.T) {
+	mux := NewServeMuxWithConcurrency("/", 1)
+	mux.AddProducer("panic", &testProducer{panic: "it-happens"})
+	mux.AddProducer("missing", &testProducer{err: os.ErrNotExist})
+	mux.AddProducer("fast", nopProducer{})
+
+	for _, key := range []string{"panic", "missing", "panic", "missing"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+key+"/name", nil))
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast/name", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServeHTTP_ProducerStatusError_StatusAndMessageWritten(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key1", &testProducer{
+		err: StatusError{Code: http.StatusUnprocessableEntity, Message: "File is corrupt"},
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key1/name", nil))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "File is corrupt\n", w.Body.String())
+}
+
+func TestServeHTTP_ProducerWrappedStatusError_StatusAndMessageWritten(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key1", &testProducer{
+		err: fmt.Errorf("Reading name: %w", StatusError{Code: http.StatusConflict, Message: "Try later"}),
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key1/name", nil))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "Try later\n", w.Body.String())
+}
+
+func TestServeHTTP_ProducerStatusErrorWithInvalidCode_StatusInternalServerErrorWritten(t *testing.T) {
+	for _, code := range []int{0, 42, http.StatusOK, 1000} {
+		mux := NewServeMux("/")
+		mux.AddProducer("key1", &testProducer{
+			err: StatusError{Code: code, Message: "File is corrupt"},
+		})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key1/name", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code, "code: %d", code)
+		assert.Equal(t, "File is corrupt\n", w.Body.String(), "code: %d", code)
+	}
+}
+
+func TestStatusError_Error_CodeAndMessageReturned(t *testing.T) {
+	err := StatusError{Code: http.StatusUnprocessableEntity, Message: "File is corrupt"}
+	assert.EqualError(t, err, "422 File is corrupt")
+}
+
+func TestServeHTTP_UnmappedKeyWithDebug_SupportedKeysWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key3/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key2", &testProducer{})
+	mux.AddProducer("key1", &testProducer{})
+	mux.SetDebug(true)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, "GET /key3/name: key3 is not supported, supported keys: key1, key2\n", w.Body.String())
+}
+
+func TestServeHTTP_UnknownPathWithDebug_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key1", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key1", &testProducer{})
+	mux.SetDebug(true)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "404 page not found\n", w.Body.String())
+}
+
+// rowsReader provides the specified number of rows named after their index.
+type rowsReader int
+
+func (n rowsReader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	confirm <- nil
+	for i := 0; i < int(n); i++ {
+		select {
+		case rows <- spreadsheet.Row{Name: fmt.Sprintf("row%d", i)}:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func TestServeHTTP_Limit_OnlyLimitedRowsRendered(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name?limit=10", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(20)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 10, strings.Count(w.Body.String(), "<td>row"))
+	assert.Contains(t, w.Body.String(), "<td>row9</td>")
+	assert.NotContains(t, w.Body.String(), "<td>row10</td>")
+}
+
+func TestServeHTTP_NoLimit_AllRowsRendered(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(20)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 20, strings.Count(w.Body.String(), "<td>row"))
+}
+
+func TestServeHTTP_InvalidLimit_StatusBadRequestWritten(t *testing.T) {
+	for _, limit := range []string{"-1", "ten", "1.5"} {
+		r := httptest.NewRequest(http.MethodGet, "/csv/name?limit="+limit, nil)
+		w := httptest.NewRecorder()
+
+		p := &testProducer{}
+		mux := NewServeMux("/")
+		mux.AddProducer("csv", p)
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+		assert.Equal(t, fmt.Sprintf("Invalid limit %q\n", limit), w.Body.String())
+		assert.Nil(t, p.htmlWriter, limit)
+	}
+}
+
+func TestServeHTTP_LimitNotSupported_LimitIgnored(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name?limit=10", nil)
+	w := httptest.NewRecorder()
+
+	p := &testProducer{}
+	mux := NewServeMux("/")
+	mux.AddProducer("key", p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "name", p.htmlName)
+}
+
+type layoutProducer struct {
+	nopProducer
+	layout interface{}
+	err    error
+}
+
+func (p *layoutProducer) Layout(name string) (interface{}, error) {
+	return p.layout, p.err
+}
+
+func TestServeHTTP_DebugLayout_LayoutWrittenAsJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name?debug=layout", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &layoutProducer{layout: map[string]int{"Name": 0}})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"Name\":0}\n", w.Body.String())
+}
+
+func TestServeHTTP_DebugLayoutNotSupported_StatusNotImplementedWritten(t *testing.T) {
+	for _, p := range []Producer{nopProducer{}, spreadsheet.NewProducer(rowsReader(1))} {
+		r := httptest.NewRequest(http.MethodGet, "/key/name?debug=layout", nil)
+		w := httptest.NewRecorder()
+
+		mux := NewServeMux("/")
+		mux.AddProducer("key", p)
+		mux.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assert.Equal(t, "Layout is not supported\n", w.Body.String())
+	}
+}
+
+func TestServeHTTP_DebugLayoutNotExist_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name?debug=layout", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &layoutProducer{err: os.ErrNotExist})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServeHTTP_UnknownDebug_StatusBadRequestWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name?debug=rows", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &layoutProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Unknown debug \"rows\"\n", w.Body.String())
+}
+
+type csvProducer struct {
+	contentType string
+}
+
+func (p *csvProducer) HTML(w io.Writer, name string) error {
+	// the header is checked at the time of writing.
+	p.contentType = w.(http.ResponseWriter).Header().Get("Content-Type")
+	_, err := io.WriteString(w, "Name\nStewart\n")
+	return err
+}
+
+func (p *csvProducer) ContentType() string {
+	return ContentTypeCSV
+}
+
+func TestServeHTTP_HTMLProducer_HTMLContentTypeWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(1)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestServeHTTP_ContentTypeProducer_ContentTypeSetBeforeWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	p := &csvProducer{}
+	mux := NewServeMux("/")
+	mux.AddProducer("key", p)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", p.contentType)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestServeHTTP_ProducerError_PlainTextContentTypeWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &testProducer{err: errors.New("oops")})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+type exportProducer struct {
+	content string
+}
+
+func (p exportProducer) HTML(w io.Writer, name string) error {
+	_, err := io.WriteString(w, p.content)
+	return err
+}
+
+func (p exportProducer) ContentType() string {
+	return ContentTypeCSV
+}
+
+func (p exportProducer) Buffered() bool {
+	return true
+}
+
+func TestServeHTTP_RangeOnGeneratedOutput_RangeIgnored(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name", nil)
+	r.Header.Set("Range", "bytes=0-9")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(5)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "none", w.Header().Get("Accept-Ranges"))
+	assert.Contains(t, w.Body.String(), "<td>row4</td>")
+	assert.Contains(t, w.Body.String(), "</html>")
+}
+
+func TestServeHTTP_RangeOnBufferedOutput_PartialContentWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/export/name", nil)
+	r.Header.Set("Range", "bytes=5-11")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("export", exportProducer{content: "Name\nStewart\nLeon\n"})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "Stewart", w.Body.String())
+	assert.Equal(t, "bytes 5-11/18", w.Header().Get("Content-Range"))
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestServeHTTP_RangeOnCSVExport_PartialContentWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv-export/name", nil)
+	r.Header.Set("Range", "bytes=0-3")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv-export", spreadsheet.NewCSVProducer(rowsReader(5)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "Name", w.Body.String())
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Range"), "bytes 0-3/"))
+}
+
+func TestServeHTTP_BufferedOutputWithoutRange_WholeContentWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/export/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("export", exportProducer{content: "Name\nStewart\nLeon\n"})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "Name\nStewart\nLeon\n", w.Body.String())
+}
+
+func TestServeHTTP_UnsatisfiableRangeOnBufferedOutput_StatusRangeNotSatisfiableWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/export/name", nil)
+	r.Header.Set("Range", "bytes=100-200")
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("export", exportProducer{content: "Name\n"})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+}
+
+func TestServeHTTP_BufferedOutput_ContentLengthWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/export/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("export", exportProducer{content: "Name\nStewart\nLeon\n"})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "18", w.Header().Get("Content-Length"))
+}
+
+func TestServeHTTP_SummaryProducer_ContentLengthWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/summary/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("summary", spreadsheet.NewSummaryProducer(rowsReader(5)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, fmt.Sprint(w.Body.Len()), w.Header().Get("Content-Length"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestServeHTTP_StreamedOutput_NoContentLength(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(5)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Length"))
+}
+
+// rawFileReader is a spreadsheet reader of the only file named "name".
+type rawFileReader struct {
+	rowsReader
+	content string
+}
+
+func (r rawFileReader) Raw(name string) (io.ReadCloser, error) {
+	if name != "name" {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(r.content)), nil
+}
+
+func TestServeHTTP_DebugRaw_FirstLinesWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name?debug=raw&lines=3", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rawFileReader{content: "1\n2\n3\n4\n"}))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1\n2\n3\n", w.Body.String())
+}
+
+func TestServeHTTP_DebugRawMissingFile_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/other?debug=raw", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rawFileReader{}))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServeHTTP_DebugRawInvalidLines_StatusBadRequestWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name?debug=raw&lines=-3", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rawFileReader{}))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Invalid lines \"-3\"\n", w.Body.String())
+}
+
+func TestServeHTTP_DebugRawNotSupported_StatusNotImplementedWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/csv/name?debug=raw", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", spreadsheet.NewProducer(rowsReader(1)))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, "Raw content is not supported\n", w.Body.String())
+}
+
+// probeProducer is a Producer which probe fails with err.
+type probeProducer struct {
+	testProducer
+	err error
+}
+
+func (p *probeProducer) Probe() error {
+	return p.err
+}
+
+func TestServeHTTP_ReadyPathAllProbesOk_StatusOKWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetReadyPath("/readyz")
+	mux.AddProducer("csv", spreadsheet.NewProducer(csv.NewReader(loader.NewFS(t.TempDir()))))
+	mux.AddProducer("plain", &testProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentTypeJSON, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"csv":"ok"}`, w.Body.String())
+}
+
+func TestServeHTTP_ReadyPathMissingDataDir_StatusServiceUnavailableWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux.SetReadyPath("/readyz")
+	mux.AddProducer("csv", spreadsheet.NewProducer(csv.NewReader(loader.NewFS(missing))))
+	mux.AddProducer("mon", spreadsheet.NewProducer(mon.NewReader(loader.NewFS(t.TempDir()))))
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"csv":"unavailable","mon":"ok"}`, w.Body.String())
+}
+
+func TestServeHTTP_ReadyPathInDebugMode_ProbeErrorWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux.SetReadyPath("/readyz")
+	mux.SetDebug(true)
+	mux.AddProducer("key", &probeProducer{err: errors.New("storage is gone")})
+	mux.AddProducer("unsupported", &probeProducer{err: fmt.Errorf("no probe: %w", errors.ErrUnsupported)})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"key":"storage is gone"}`, w.Body.String())
+}
+
+// hungProbeProducer blocks in Probe until released.
+type hungProbeProducer struct {
+	testProducer
+	release chan struct{}
+}
+
+func (p *hungProbeProducer) Probe() error {
+	<-p.release
+	return nil
+}
+
+func TestServeHTTP_ReadyPathProbeHangs_StatusServiceUnavailableWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	hung := &hungProbeProducer{release: make(chan struct{})}
+	defer close(hung.release)
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux.SetReadyPath("/readyz")
+	mux.SetProbeTimeout(10 * time.Millisecond)
+	mux.AddProducer("hung", hung)
+	mux.AddProducer("key", &probeProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"hung":"unavailable","key":"ok"}`, w.Body.String())
+}
+
+func TestServeHTTP_ReadyPathProbeHangsInDebugMode_TimeoutWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	hung := &hungProbeProducer{release: make(chan struct{})}
+	defer close(hung.release)
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux.SetReadyPath("/readyz")
+	mux.SetDebug(true)
+	mux.SetProbeTimeout(10 * time.Millisecond)
+	mux.AddProducer("hung", hung)
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"hung":"Probe timed out after 10ms"}`, w.Body.String())
+}
+
+func TestServeHTTP_NoReadyPath_StatusNotFoundWritten(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", &probeProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// streamingProducer writes output in two parts with a delay between them.
+type streamingProducer struct {
+	delay time.Duration
+}
+
+func (p streamingProducer) HTML(w io.Writer, name string) error {
+	io.WriteString(w, "<p>first</p>")
+	time.Sleep(p.delay)
+	io.WriteString(w, "<p>second</p>")
+	return nil
+}
+
+func TestServeHTTP_SlowStreamingProducer_SlowWarningLogged(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+	logBuf := bytes.Buffer{}
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(&logBuf, nil)))
+	mux.SetSlowThreshold(10 * time.Millisecond)
+	mux.AddProducer("key", streamingProducer{delay: 30 * time.Millisecond})
+	mux.ServeHTTP(w, r)
+
+	var entry map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(logBuf.Bytes(), &entry)) {
+		assert.Equal(t, "WARN", entry["level"])
+		assert.True(t, strings.HasPrefix(entry["msg"].(string), "[SLOW] "), entry["msg"])
+		assert.Equal(t, "key", entry["producer"])
+		assert.Equal(t, "name", entry["name"])
+	}
+	assert.Equal(t, "<p>first</p><p>second</p>", w.Body.String())
+}
+
+func TestServeHTTP_FastProducer_NoSlowWarningLogged(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/key/name", nil)
+	w := httptest.NewRecorder()
+	logBuf := bytes.Buffer{}
+
+	mux := NewServeMux("/")
+	mux.SetLogger(slog.New(slog.NewJSONHandler(&logBuf, nil)))
+	mux.SetSlowThreshold(time.Minute)
+	mux.AddProducer("key", streamingProducer{})
+	mux.ServeHTTP(w, r)
+
+	assert.Empty(t, logBuf.String())
+}
+
+func TestServeHTTP_RateLimitExceeded_StatusTooManyRequestsWritten(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &testProducer{})
+	mux.AddProducer("other", &testProducer{})
+	mux.SetProducerRateLimit("key", 20, 2)
+	serve := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/key/name").Code)
+	assert.Equal(t, http.StatusOK, serve("/key/name").Code)
+	w := serve("/key/name")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serve("/other/name").Code)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serve("/key/name").Code)
+}
+
+func TestServeHTTP_RateLimitRemoved_RequestsNotLimited(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key", &testProducer{})
+	mux.SetProducerRateLimit("key", 0.001, 1)
+	mux.SetProducerRateLimit("key", 0, 0)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+		assert.Equal(t, http.StatusOK, w.Code, "request %d", i)
+	}
+}
+
+func TestServeHTTP_RateLimitConcurrentRequests_BurstAllowed(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("key", nopProducer{})
+	mux.SetProducerRateLimit("key", 0.001, 5)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/name", nil))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			ok++
+		}
+	}
+	assert.Equal(t, 5, ok)
+}
@@ -0,0 +1,31 @@
+package producers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve_BurstExhausted_WaitReturned(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := newRateLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, l.reserve(start), "request %d", i)
+	}
+	assert.Equal(t, 500*time.Millisecond, l.reserve(start))
+	assert.Equal(t, 250*time.Millisecond, l.reserve(start.Add(250*time.Millisecond)))
+}
+
+func TestRateLimiterReserve_TimePassed_TokensRefilledUpToBurst(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := newRateLimiter(2, 2)
+	l.reserve(start)
+	l.reserve(start)
+
+	later := start.Add(time.Hour)
+	assert.Zero(t, l.reserve(later))
+	assert.Zero(t, l.reserve(later))
+	assert.NotZero(t, l.reserve(later))
+}
@@ -0,0 +1,75 @@
+package producers
+
+import (
+	"fmt"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+	"sort"
+
+	// formats that are always available to Register.
+	_ "registry-sample/readers/csv"
+	_ "registry-sample/readers/dat"
+	_ "registry-sample/readers/fixed"
+	_ "registry-sample/readers/json"
+	_ "registry-sample/readers/kv"
+	_ "registry-sample/readers/mon"
+	_ "registry-sample/readers/xlsx"
+)
+
+// ProducerSpec describes a spreadsheet Producer to be registered by Register.
+type ProducerSpec struct {
+	// Format is the key of a format registered in package readers,
+	// e.g. csv, mon, xlsx, json, dat, fixed or kv.
+	Format string
+	// Loader provides access to spreadsheet files.
+	Loader loader.Interface
+	// PageSize makes the output printable with a page break after
+	// each PageSize rows if it's positive.
+	PageSize int
+	// RateLimit limits requests to the Producer to RateLimit
+	// requests per second with bursts of Burst requests if it's
+	// positive, see ServeMux.SetProducerRateLimit.
+	RateLimit float64
+	Burst     int
+}
+
+// Register adds spreadsheet Producers built according to the specified
+// specs to mux under the keys of the specs. If a format is unknown,
+// the error is returned and nothing is added. Producers are added in
+// the order of keys, so a key that fails to be added stops the rest.
+func Register(mux *ServeMux, specs map[string]ProducerSpec) error {
+	keys := make([]string, 0, len(specs))
+	known := map[string]bool{}
+	for _, format := range readers.Formats() {
+		known[format] = true
+	}
+	for key, spec := range specs {
+		if !known[spec.Format] {
+			return fmt.Errorf("Unknown format %s of Producer with key %s", spec.Format, key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		spec := specs[key]
+		reader, err := readers.New(spec.Format, spec.Loader)
+		if err != nil {
+			return err
+		}
+		var p *spreadsheet.Producer
+		if spec.PageSize > 0 {
+			p = spreadsheet.NewPrintProducer(reader, spec.PageSize)
+		} else {
+			p = spreadsheet.NewProducer(reader)
+		}
+		if err := mux.AddProducer(key, p); err != nil {
+			return err
+		}
+		if spec.RateLimit > 0 {
+			mux.SetProducerRateLimit(key, spec.RateLimit, spec.Burst)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package producers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister_Specs_KeysRouteToProducers(t *testing.T) {
+	csvLoader := loader.NewTest("Name,Address\n\"Stewart, Jamie\",Voorstraat 47\n")
+	monLoader := loader.NewTest("Name           Address\nLeon, Mike     Dorpsplein 5A\n")
+	printLoader := loader.NewTest("Name\nKling\nGibson\nNordberg\n")
+	mux := NewServeMux("/")
+
+	err := Register(mux, map[string]ProducerSpec{
+		"csv":       {Format: "csv", Loader: csvLoader},
+		"mon":       {Format: "mon", Loader: monLoader},
+		"csv-print": {Format: "csv", Loader: printLoader, PageSize: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"csv", "csv-print", "mon"}, mux.ListProducers())
+
+	serve := func(url string) string {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		return w.Body.String()
+	}
+	assert.Contains(t, serve("/csv/name1"), "<td>Stewart, Jamie</td>")
+	assert.Equal(t, "name1.csv", csvLoader.LoadName)
+	assert.Contains(t, serve("/mon/name2"), "<td>Leon, Mike</td>")
+	assert.Equal(t, "name2.mon", monLoader.LoadName)
+	assert.Contains(t, serve("/csv-print/name3"), "page-break-after")
+	assert.Equal(t, "name3.csv", printLoader.LoadName)
+}
+
+func TestRegister_UnknownFormat_ErrorReturnedAndNothingAdded(t *testing.T) {
+	mux := NewServeMux("/")
+
+	err := Register(mux, map[string]ProducerSpec{
+		"csv": {Format: "csv", Loader: loader.NewTest("")},
+		"xls": {Format: "xls", Loader: loader.NewTest("")},
+	})
+
+	assert.EqualError(t, err, "Unknown format xls of Producer with key xls")
+	assert.Empty(t, mux.ListProducers())
+}
+
+func TestRegister_KeyAlreadyRegistered_ErrorReturned(t *testing.T) {
+	mux := NewServeMux("/")
+	mux.AddProducer("csv", nopProducer{})
+
+	err := Register(mux, map[string]ProducerSpec{
+		"csv": {Format: "csv", Loader: loader.NewTest("")},
+	})
+
+	assert.EqualError(t, err, "Another Producer with key csv is already registered")
+}
+
+func TestRegister_RateLimit_LimitApplied(t *testing.T) {
+	mux := NewServeMux("/")
+
+	err := Register(mux, map[string]ProducerSpec{
+		"csv": {Format: "csv", Loader: loader.NewTest("Name\nKling\n"), RateLimit: 0.001},
+	})
+	assert.NoError(t, err)
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/csv/name1", nil))
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
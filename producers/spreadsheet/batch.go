@@ -0,0 +1,92 @@
+package spreadsheet
+
+import "fmt"
+
+// DefaultBatchSize is the number of rows in a batch
+// for readers that batch rows unless told otherwise.
+const DefaultBatchSize = 64
+
+// BatchReader is an optional interface implemented by Readers that are
+// able to send rows in batches. It saves the cost of passing every row
+// between goroutines, which matters for large spreadsheets. Producer
+// reads such readers by ReadBatches rather than Read.
+type BatchReader interface {
+	// ReadBatches acts as Read but sends rows through the batches
+	// channel in batches of any size. A batch must not be changed
+	// once it's sent.
+	ReadBatches(name string, confirm chan<- error, batches chan<- []Row, stop <-chan struct{})
+}
+
+// RowBatcher accumulates rows of a BatchReader and sends them once
+// there are enough of them for a batch. Since rows are held until a
+// batch is full, Flush must be called once rows are over.
+type RowBatcher struct {
+	batches chan<- []Row
+	size    int
+	batch   []Row
+}
+
+// NewRowBatcher creates and initializes a new instance of RowBatcher that
+// sends batches of the specified size to the specified channel.
+func NewRowBatcher(batches chan<- []Row, size int) *RowBatcher {
+	if size <= 0 {
+		panic(fmt.Sprintf("Invalid batch size %d", size))
+	}
+	return &RowBatcher{batches: batches, size: size}
+}
+
+// Add adds the row to the current batch and sends the batch if it's full.
+func (b *RowBatcher) Add(row Row) {
+	if b.batch == nil {
+		b.batch = make([]Row, 0, b.size)
+	}
+	b.batch = append(b.batch, row)
+	if len(b.batch) == b.size {
+		b.Flush()
+	}
+}
+
+// Flush sends the current batch if it has any rows.
+func (b *RowBatcher) Flush() {
+	if len(b.batch) == 0 {
+		return
+	}
+	b.batches <- b.batch
+	b.batch = nil
+}
+
+// ReadUnbatched reads the spreadsheet with the specified name by
+// ReadBatches of the reader and sends its rows one by one, so that
+// a BatchReader is able to implement Read as well. Panics of
+// ReadBatches are passed to the caller.
+func ReadUnbatched(br BatchReader, name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	batches := make(chan []Row)
+	var panicked interface{}
+	go func() {
+		defer close(batches)
+		defer func() { panicked = recover() }()
+		br.ReadBatches(name, confirm, batches, stop)
+	}()
+
+	for batch := range batches {
+		for _, row := range batch {
+			rows <- row
+		}
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// drainRows reads rows and batches until their channels
+// are closed. Either channel may be nil.
+func drainRows(rows <-chan Row, batches <-chan []Row) {
+	if rows != nil {
+		for _ = range rows {
+		}
+	}
+	if batches != nil {
+		for _ = range batches {
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batchReader sends rows in batches of the specified size.
+type batchReader struct {
+	rows  []Row
+	size  int
+	panic interface{}
+}
+
+func (r *batchReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	ReadUnbatched(r, name, confirm, rows, stop)
+}
+
+func (r *batchReader) ReadBatches(name string, confirm chan<- error, batches chan<- []Row, stop <-chan struct{}) {
+	confirm <- nil
+	if r.panic != nil {
+		panic(r.panic)
+	}
+	b := NewRowBatcher(batches, r.size)
+	defer b.Flush()
+	for _, row := range r.rows {
+		select {
+		case <-stop:
+			return
+		default:
+			b.Add(row)
+		}
+	}
+}
+
+func makeRows(n int) []Row {
+	errMsg := "oops sorry"
+	rows := make([]Row, n)
+	for i := range rows {
+		if i%10 == 9 {
+			rows[i] = Row{ErrorMessage: &errMsg}
+			continue
+		}
+		rows[i] = Row{Name: "name" + strconv.Itoa(i), CreditLimit: strconv.Itoa(i * 100)}
+	}
+	return rows
+}
+
+func TestHtml_BatchReader_SameOutputAsPerRow(t *testing.T) {
+	rows := makeRows(95)
+	var perRow bytes.Buffer
+	assert.NoError(t, NewProducer(&testReader{rows: rows}).HTML(&perRow, "name"))
+
+	for _, size := range []int{1, 7, 64, 100} {
+		var batched bytes.Buffer
+		err := NewProducer(&batchReader{rows: rows, size: size}).HTML(&batched, "name")
+		assert.NoError(t, err, "size: %d", size)
+		assert.Equal(t, perRow.String(), batched.String(), "size: %d", size)
+	}
+}
+
+func TestHtmlLimit_BatchReader_StatsOfLimitedRows(t *testing.T) {
+	var stats ReadStats
+	p := NewProducer(&batchReader{rows: makeRows(95), size: 10})
+	p.ReportStats(func(s ReadStats) { stats = s })
+
+	err := p.HTMLLimit(&bytes.Buffer{}, "name", 15)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 15, stats.Rows)
+	assert.True(t, stats.Stopped)
+}
+
+func TestLimitErrorRows_BatchReader_ReadAborted(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProducer(&batchReader{rows: makeRows(95), size: 8})
+	p.LimitErrorRows(2)
+
+	err := p.HTML(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "Too many errors, read is aborted after 2 error rows")
+	assert.NotContains(t, buf.String(), "name30")
+}
+
+func TestHtml_BatchReaderPaniced_ErrorReturned(t *testing.T) {
+	p := NewProducer(&batchReader{panic: "it-happens"})
+	err := p.HTML(&bytes.Buffer{}, "name")
+	assert.EqualError(t, err, "Reader *spreadsheet.batchReader paniced on name: it-happens")
+}
+
+func TestReadUnbatched_BatchReader_RowsSentOneByOne(t *testing.T) {
+	rows := makeRows(25)
+	confirm := make(chan error, 1)
+	received := make(chan Row)
+	go func() {
+		defer close(received)
+		(&batchReader{rows: rows, size: 4}).Read("name", confirm, received, nil)
+	}()
+
+	var all []Row
+	for row := range received {
+		all = append(all, row)
+	}
+	assert.NoError(t, <-confirm)
+	assert.Equal(t, rows, all)
+}
+
+func TestReadUnbatched_Paniced_PanicPassedToCaller(t *testing.T) {
+	r := &batchReader{panic: "it-happens"}
+	assert.Panics(t, func() { r.Read("name", make(chan error, 1), make(chan Row), nil) })
+}
+
+func TestRowBatcher_Rows_SentInBatchesOfSize(t *testing.T) {
+	batches := make(chan []Row, 10)
+	b := NewRowBatcher(batches, 2)
+	for _, row := range makeRows(5) {
+		b.Add(row)
+	}
+	b.Flush()
+	b.Flush()
+	close(batches)
+
+	var sizes []int
+	for batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+}
+
+func TestNewRowBatcher_InvalidSize_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewRowBatcher(make(chan []Row), 0) })
+}
+
+// benchmarkRun measures throughput of rows from the reader to a consumer.
+func benchmarkRun(b *testing.B, reader Reader) {
+	p := NewProducer(reader)
+	for i := 0; i < b.N; i++ {
+		err := p.run(context.Background(), "name", nil, "Benchmark", func(rows <-chan Row, stop func()) error {
+			for _ = range rows {
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRun_PerRow(b *testing.B) {
+	benchmarkRun(b, &testReader{rows: makeRows(10000)})
+}
+
+func BenchmarkRun_Batched(b *testing.B) {
+	for _, size := range []int{16, DefaultBatchSize, 256} {
+		b.Run(fmt.Sprintf("size%d", size), func(b *testing.B) {
+			benchmarkRun(b, &batchReader{rows: makeRows(10000), size: size})
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package spreadsheet
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Column describes a spreadsheet column known to readers.
+type Column struct {
+	// Name is the title of the column in spreadsheet headers.
+	Name string
+	// Set assigns the column's value to the corresponding field of row.
+	Set func(row *Row, value string)
+	// Get returns the column's value from the corresponding field of row.
+	Get func(row Row) string
+}
+
+// Columns lists all known columns in the order they are displayed.
+var Columns = []Column{
+	{
+		Name: "Name",
+		Set:  func(row *Row, v string) { row.Name = v },
+		Get:  func(row Row) string { return row.Name },
+	},
+	{
+		Name: "Address",
+		Set:  func(row *Row, v string) { row.Address = v },
+		Get:  func(row Row) string { return row.Address },
+	},
+	{
+		Name: "Postcode",
+		Set:  func(row *Row, v string) { row.Postcode = v },
+		Get:  func(row Row) string { return row.Postcode },
+	},
+	{
+		Name: "Phone",
+		Set:  func(row *Row, v string) { row.Phone = v },
+		Get:  func(row Row) string { return row.Phone },
+	},
+	{
+		Name: "Credit Limit",
+		Set:  func(row *Row, v string) { row.CreditLimit = v },
+		Get:  func(row Row) string { return row.CreditLimit },
+	},
+	{
+		Name: "Birthday",
+		Set:  func(row *Row, v string) { row.Birthday = v },
+		Get:  func(row Row) string { return row.Birthday },
+	},
+	{
+		Name: "Email",
+		Set:  func(row *Row, v string) { row.Email = v },
+		Get:  func(row Row) string { return row.Email },
+	},
+	{
+		Name: "Company",
+		Set:  func(row *Row, v string) { row.Company = v },
+		Get:  func(row Row) string { return row.Company },
+	},
+}
+
+// FindColumn returns the known column with the specified name
+// ignoring case. If there is no such column, false is returned.
+func FindColumn(name string) (Column, bool) {
+	for _, col := range Columns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// ResolveColumn acts as FindColumn but first maps name to a canonical
+// one through aliases, e.g. "Zip" to "Postcode". Keys of aliases are
+// matched ignoring case as well.
+func ResolveColumn(name string, aliases map[string]string) (Column, bool) {
+	for alias, canonical := range aliases {
+		if strings.EqualFold(alias, name) {
+			return FindColumn(canonical)
+		}
+	}
+	return FindColumn(name)
+}
+
+// NormalizeBirthday converts birthday in any of the specified layouts to
+// "2006-01-02" format. If birthday doesn't match the layouts, it's
+// returned as is.
+func NormalizeBirthday(birthday string, layouts ...string) string {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, birthday); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return birthday
+}
+
+// InvalidPhoneMarker is appended by NormalizePhone to numbers
+// that obviously can't be valid.
+const InvalidPhoneMarker = " (invalid)"
+
+// NormalizePhone converts the specified phone number to a canonical form
+// that contains only digits with an optional leading plus sign. Spaces,
+// dashes, dots and parentheses are stripped. If the number contains other
+// characters or has unrealistic number of digits, it's returned as is
+// with InvalidPhoneMarker appended.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	var b strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return phone + InvalidPhoneMarker
+		}
+	}
+	digits := len(strings.TrimPrefix(b.String(), "+"))
+	if digits < 6 || digits > 15 {
+		return phone + InvalidPhoneMarker
+	}
+	return b.String()
+}
+
+// InvalidPostcodeMarker is appended by NormalizePostcode to postcodes
+// that don't match any known pattern.
+const InvalidPostcodeMarker = " (invalid)"
+
+// postcodeRules are known postcode patterns along with replacements
+// that bring matching postcodes to a canonical form.
+var postcodeRules = []struct {
+	pattern *regexp.Regexp
+	format  string
+}{
+	// Dutch, e.g. 1234 AB
+	{regexp.MustCompile(`^([0-9]{4}) ?([A-Z]{2})$`), "$1 $2"},
+	// five digits, e.g. American or German
+	{regexp.MustCompile(`^([0-9]{5})$`), "$1"},
+	// British, e.g. SW1A 1AA
+	{regexp.MustCompile(`^([A-Z]{1,2}[0-9][A-Z0-9]?) ?([0-9][A-Z]{2})$`), "$1 $2"},
+}
+
+// NormalizePostcode converts the specified postcode to a canonical form
+// according to the known patterns. Letters are uppercased and parts are
+// separated by a single space, e.g. "1234ab" becomes "1234 AB". If the
+// postcode doesn't match any pattern, it's returned as is with
+// InvalidPostcodeMarker appended.
+func NormalizePostcode(postcode string) string {
+	postcode = strings.TrimSpace(postcode)
+	canonical := strings.ToUpper(strings.Join(strings.Fields(postcode), " "))
+	for _, rule := range postcodeRules {
+		if rule.pattern.MatchString(canonical) {
+			return rule.pattern.ReplaceAllString(canonical, rule.format)
+		}
+	}
+	return postcode + InvalidPostcodeMarker
+}
+
+// thousandsPattern matches a decimal number with thousands
+// separated by commas, e.g. 1,234.5.
+var thousandsPattern = regexp.MustCompile(`^-?[0-9]{1,3}(,[0-9]{3})+(\.[0-9]+)?$`)
+
+// NormalizeCreditLimit converts the specified credit limit to a decimal
+// with two digits after the point, e.g. "$1,234.5" becomes "1234.50".
+// Currency symbols and spaces are stripped, commas are allowed only as
+// thousands separators. If the credit limit isn't a number then, it's
+// returned as is.
+func NormalizeCreditLimit(creditLimit string) string {
+	v := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		return r
+	}, creditLimit)
+	if strings.Contains(v, ",") {
+		if !thousandsPattern.MatchString(v) {
+			return creditLimit
+		}
+		v = strings.Replace(v, ",", "", -1)
+	}
+	if !creditLimitPattern.MatchString(v) {
+		return creditLimit
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return creditLimit
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
@@ -0,0 +1,67 @@
+package spreadsheet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhone_VariousInputs_CanonicalFormReturned(t *testing.T) {
+	testCases := []struct {
+		phone string
+		want  string
+	}{
+		{phone: "+1 709 880038", want: "+1709880038"},
+		{phone: "020 7899381", want: "0207899381"},
+		{phone: "0156-210475", want: "0156210475"},
+		{phone: " (030) 228.89.86 ", want: "0302288986"},
+		{phone: "12-34", want: "12-34 (invalid)"},
+		{phone: "call me", want: "call me (invalid)"},
+		{phone: "020+7899381", want: "020+7899381 (invalid)"},
+		{phone: "", want: " (invalid)"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, NormalizePhone(tc.phone), "phone: %q", tc.phone)
+	}
+}
+
+func TestNormalizePostcode_VariousInputs_CanonicalFormReturned(t *testing.T) {
+	testCases := []struct {
+		postcode string
+		want     string
+	}{
+		{postcode: "3123gg", want: "3123 GG"},
+		{postcode: "4532 AA", want: "4532 AA"},
+		{postcode: " 1234  ab ", want: "1234 AB"},
+		{postcode: "91455", want: "91455"},
+		{postcode: "sw1a1aa", want: "SW1A 1AA"},
+		{postcode: "M1 1AE", want: "M1 1AE"},
+		{postcode: "12345AB", want: "12345AB (invalid)"},
+		{postcode: "not a postcode", want: "not a postcode (invalid)"},
+		{postcode: "", want: " (invalid)"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, NormalizePostcode(tc.postcode), "postcode: %q", tc.postcode)
+	}
+}
+
+func TestNormalizeCreditLimit_VariousInputs_CanonicalFormReturned(t *testing.T) {
+	testCases := []struct {
+		creditLimit string
+		want        string
+	}{
+		{creditLimit: "$1,234.5", want: "1234.50"},
+		{creditLimit: "50000", want: "50000.00"},
+		{creditLimit: "N/A", want: "N/A"},
+		{creditLimit: " € 12.345 ", want: "12.35"},
+		{creditLimit: "-£1,000", want: "-1000.00"},
+		{creditLimit: "1,23", want: "1,23"},
+		{creditLimit: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, NormalizeCreditLimit(tc.creditLimit), "credit limit: %q", tc.creditLimit)
+	}
+}
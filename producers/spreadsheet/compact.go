@@ -0,0 +1,56 @@
+package spreadsheet
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// HTMLCompact generates output to display spreadsheet as a web page
+// omitting columns that are empty in every row. Since it's unknown which
+// columns are empty until the last row is read, all rows are buffered
+// before anything is written. So unlike HTML, the output isn't streamed
+// and memory usage grows with the size of the spreadsheet.
+func (p *Producer) HTMLCompact(w io.Writer, name string) error {
+	return p.run(context.Background(), name, nil, "Template", func(rows <-chan Row, stop func()) error {
+		var buffered []Row
+		for row := range rows {
+			buffered = append(buffered, row)
+		}
+
+		replay := make(chan Row, len(buffered))
+		for _, row := range buffered {
+			replay <- row
+		}
+		close(replay)
+
+		t := p.parseTemplate(p.body, false, emptyColumns(buffered))
+		return p.execute(w, name, nil, t, replay, stop)
+	})
+}
+
+// emptyColumns returns names of columns that are blank in every row.
+// Error rows don't count. If there is no row with data, no column is
+// considered empty.
+func emptyColumns(rows []Row) map[string]bool {
+	empty := map[string]bool{}
+	for _, col := range Columns {
+		empty[col.Name] = true
+	}
+	data := false
+	for _, row := range rows {
+		if row.ErrorMessage != nil {
+			continue
+		}
+		data = true
+		for _, col := range Columns {
+			if strings.TrimSpace(col.Get(row)) != "" {
+				delete(empty, col.Name)
+			}
+		}
+	}
+	if !data {
+		return nil
+	}
+	return empty
+}
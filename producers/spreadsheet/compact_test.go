@@ -0,0 +1,75 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlCompact_PhoneAlwaysEmpty_PhoneColumnOmitted(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47", Postcode: "3123gg", CreditLimit: "50000", Birthday: "1982-02-01", Email: "jamie@example.com", Company: "Acme"},
+		{ErrorMessage: &errMsg},
+		{Name: "Leon, Mike", Phone: " ", Postcode: "4532 AA"},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLCompact(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Postcode</td><td>Credit Limit</td>")
+	assert.NotContains(t, s, "Phone")
+	assert.Contains(t, s, "<td>3123gg</td><td align=\"right\">50000</td>")
+	assert.Contains(t, s, `<td colspan="7">oops sorry</td>`)
+}
+
+func TestHtmlCompact_AllColumnsPopulated_AllColumnsRendered(t *testing.T) {
+	r := &testReader{rows: []Row{
+		{Name: "a", Address: "b", Postcode: "c", Phone: "d", CreditLimit: "1", Birthday: "e", Email: "f", Company: "g"},
+	}}
+	var compact, regular bytes.Buffer
+
+	p := NewProducer(r)
+	assert.NoError(t, p.HTMLCompact(&compact, "name"))
+	assert.NoError(t, p.HTML(&regular, "name"))
+
+	assert.Equal(t, regular.String(), compact.String())
+}
+
+func TestHtmlCompact_OnlyErrorRows_AllColumnsRendered(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{{ErrorMessage: &errMsg}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLCompact(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<td>Phone</td>")
+	assert.Contains(t, buf.String(), `<td colspan="8">oops sorry</td>`)
+}
+
+func TestHtmlCompact_EmptyRead_NoRecordsPlaceholder(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{})
+	err := p.HTMLCompact(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "No records found")
+}
+
+func TestHtmlCompact_ReadError_ErrorReturned(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{err: errors.New("must read, but won't")})
+	err := p.HTMLCompact(&buf, "name")
+
+	assert.EqualError(t, err, "must read, but won't")
+	assert.Empty(t, buf.String())
+}
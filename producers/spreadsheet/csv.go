@@ -0,0 +1,105 @@
+package spreadsheet
+
+import (
+	"context"
+	"io"
+	"time"
+
+	csv_enc "encoding/csv"
+)
+
+// byteOrderMark is UTF-8 encoding of U+FEFF that tells Excel
+// the content is UTF-8.
+const byteOrderMark = "\xEF\xBB\xBF"
+
+// csvErrorPrefix precedes messages of error rows in CSV output.
+const csvErrorPrefix = "Error: "
+
+// WriteByteOrderMark makes CSV output start with UTF-8 byte order mark,
+// so that Excel doesn't mangle accented characters. It's off by default
+// since machine consumers don't expect the mark.
+func (p *Producer) WriteByteOrderMark(enabled bool) {
+	p.byteOrderMark = enabled
+}
+
+// CSV generates output in CSV format with a header of known columns
+// followed by a record for every row of spreadsheet. Error rows are
+// output as records which first field is the message prefixed with
+// "Error:" and the rest are empty.
+func (p *Producer) CSV(w io.Writer, name string) error {
+	return p.run(context.Background(), name, nil, "Writer", func(rows <-chan Row, stop func()) error {
+		return p.writeCSV(w, rows)
+	})
+}
+
+// writeCSV writes the rows in CSV format as CSV does.
+func (p *Producer) writeCSV(w io.Writer, rows <-chan Row) error {
+	if p.byteOrderMark {
+		if _, err := io.WriteString(w, byteOrderMark); err != nil {
+			return err
+		}
+	}
+	cw := csv_enc.NewWriter(w)
+	record := make([]string, len(Columns))
+	for i, col := range Columns {
+		record[i] = col.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for row := range rows {
+		for i, col := range Columns {
+			record[i] = col.Get(row)
+		}
+		if row.ErrorMessage != nil {
+			for i := range record {
+				record[i] = ""
+			}
+			record[0] = csvErrorPrefix + *row.ErrorMessage
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CSVProducer provides CSV output of Producer through the plugin
+// interface of HTML producers, e.g. to serve it over HTTP.
+type CSVProducer struct {
+	p *Producer
+}
+
+// NewCSVProducer creates and initializes a new instance of CSVProducer.
+func NewCSVProducer(reader Reader) *CSVProducer {
+	return &CSVProducer{p: NewProducer(reader)}
+}
+
+// WriteByteOrderMark makes output start with UTF-8 byte order mark,
+// see Producer.WriteByteOrderMark.
+func (cp *CSVProducer) WriteByteOrderMark(enabled bool) {
+	cp.p.WriteByteOrderMark(enabled)
+}
+
+// HTML generates CSV output despite its name, see Producer.CSV.
+func (cp *CSVProducer) HTML(w io.Writer, name string) error {
+	return cp.p.CSV(w, name)
+}
+
+// ContentType returns the media type of CSV.
+func (cp *CSVProducer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// Buffered reports that output is fine to buffer, so that
+// downloads of exports may be resumed with range requests.
+func (cp *CSVProducer) Buffered() bool {
+	return true
+}
+
+// ModTime returns modification time of the spreadsheet with the specified
+// name if the reader is a ModTimer, otherwise false is returned.
+func (cp *CSVProducer) ModTime(name string) (time.Time, bool) {
+	return cp.p.ModTime(name)
+}
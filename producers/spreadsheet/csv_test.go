@@ -0,0 +1,67 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsv_SuccessfulRead_RecordPerRow(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+		{ErrorMessage: &errMsg},
+		{Name: "Leon, Mike", CreditLimit: "201092"},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.CSV(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Name,Address,Postcode,Phone,Credit Limit,Birthday,Email,Company\n"+
+		"\"Stewart, Jamie\",,3123gg,,,,,\n"+
+		"Error: oops sorry,,,,,,,\n"+
+		"\"Leon, Mike\",,,,201092,,,\n", buf.String())
+}
+
+func TestCsv_ByteOrderMark_WrittenOnlyIfEnabled(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Müller, Jürgen"}}}
+
+	var plain bytes.Buffer
+	p := NewProducer(r)
+	assert.NoError(t, p.CSV(&plain, "name"))
+	assert.True(t, strings.HasPrefix(plain.String(), "Name,"))
+
+	var marked bytes.Buffer
+	p.WriteByteOrderMark(true)
+	assert.NoError(t, p.CSV(&marked, "name"))
+	assert.Equal(t, "\xEF\xBB\xBF"+plain.String(), marked.String())
+	assert.Equal(t, 1, strings.Count(marked.String(), "\xEF\xBB\xBF"))
+}
+
+func TestCsv_ReadError_NothingWritten(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{err: errors.New("must read, but won't")})
+	p.WriteByteOrderMark(true)
+	err := p.CSV(&buf, "name")
+
+	assert.EqualError(t, err, "must read, but won't")
+	assert.Empty(t, buf.String())
+}
+
+func TestCSVProducer_ByteOrderMark_WrittenBeforeHeader(t *testing.T) {
+	var buf bytes.Buffer
+
+	cp := NewCSVProducer(&testReader{})
+	cp.WriteByteOrderMark(true)
+	err := cp.HTML(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFName,Address,Postcode,Phone,Credit Limit,Birthday,Email,Company\n", buf.String())
+	assert.Equal(t, "text/csv; charset=utf-8", cp.ContentType())
+}
@@ -0,0 +1,129 @@
+package spreadsheet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShowUnparseableCreditLimits makes credit limit filter output rows which
+// credit limit can't be parsed as error rows instead of dropping them.
+func (p *Producer) ShowUnparseableCreditLimits(show bool) {
+	p.showUnparseable = show
+}
+
+// ShowUnparseableBirthdays makes birthday filter output rows which birthday
+// is missing or can't be parsed as error rows instead of dropping them.
+func (p *Producer) ShowUnparseableBirthdays(show bool) {
+	p.showUnparseableBirthdays = show
+}
+
+// HTMLFilterCreditLimit generates output to display only rows of spreadsheet
+// which credit limit is within [min, max]. Use math.Inf to leave a bound open.
+// Rows which credit limit can't be parsed are dropped unless
+// ShowUnparseableCreditLimits is set. Error rows are always shown.
+func (p *Producer) HTMLFilterCreditLimit(w io.Writer, name string, min, max float64) error {
+	if min > max {
+		return fmt.Errorf("Invalid credit limit range [%v, %v]", min, max)
+	}
+	return p.html(context.Background(), w, name, func(row Row) (Row, bool) {
+		if row.ErrorMessage != nil {
+			return row, true
+		}
+		v := strings.TrimSpace(row.CreditLimit)
+		limit, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			if p.showUnparseable {
+				msg := fmt.Sprintf("Unparseable credit limit %q of %s", v, row.Name)
+				return Row{ErrorMessage: &msg}, true
+			}
+			return row, false
+		}
+		return row, limit >= min && limit <= max
+	})
+}
+
+// HTMLSearch generates output to display only rows of spreadsheet that
+// have any field containing the specified query ignoring case. Error rows
+// are always shown. Empty query makes HTMLSearch act as HTML.
+func (p *Producer) HTMLSearch(w io.Writer, name, query string) error {
+	if query == "" {
+		return p.HTML(w, name)
+	}
+	query = strings.ToLower(query)
+	return p.html(context.Background(), w, name, func(row Row) (Row, bool) {
+		if row.ErrorMessage != nil {
+			return row, true
+		}
+		for _, v := range row.fields() {
+			if strings.Contains(strings.ToLower(v), query) {
+				return row, true
+			}
+		}
+		return row, false
+	})
+}
+
+// HTMLDedupe generates output to display rows of spreadsheet skipping
+// those which value of the specified key column was already shown, so
+// only the first occurrence is kept. Error rows are always shown.
+func (p *Producer) HTMLDedupe(w io.Writer, name, keyCol string) error {
+	col, ok := FindColumn(keyCol)
+	if !ok {
+		return fmt.Errorf("Unknown key column %s", keyCol)
+	}
+	seen := map[string]struct{}{}
+	return p.html(context.Background(), w, name, func(row Row) (Row, bool) {
+		if row.ErrorMessage != nil {
+			return row, true
+		}
+		key := col.Get(row)
+		if _, ok := seen[key]; ok {
+			return row, false
+		}
+		seen[key] = struct{}{}
+		return row, true
+	})
+}
+
+// HTMLFilterBirthday generates output to display only rows of spreadsheet
+// which birthday is within [from, to]. Only dates of the bounds matter and
+// a zero time leaves a bound open. Rows which birthday is missing or can't
+// be parsed are dropped unless ShowUnparseableBirthdays is set. Error rows
+// are always shown.
+func (p *Producer) HTMLFilterBirthday(w io.Writer, name string, from, to time.Time) error {
+	from, to = dateOf(from), dateOf(to)
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return fmt.Errorf("Invalid birthday range [%s, %s]", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	return p.html(context.Background(), w, name, func(row Row) (Row, bool) {
+		if row.ErrorMessage != nil {
+			return row, true
+		}
+		v := strings.TrimSpace(row.Birthday)
+		birthday, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			if p.showUnparseableBirthdays {
+				msg := fmt.Sprintf("Unparseable birthday %q of %s", v, row.Name)
+				if v == "" {
+					msg = fmt.Sprintf("Missing birthday of %s", row.Name)
+				}
+				return Row{ErrorMessage: &msg}, true
+			}
+			return row, false
+		}
+		return row, (from.IsZero() || !birthday.Before(from)) && (to.IsZero() || !birthday.After(to))
+	})
+}
+
+// dateOf returns the midnight of the date of t in UTC,
+// so that it's comparable to parsed birthdays.
+func dateOf(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
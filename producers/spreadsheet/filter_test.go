@@ -0,0 +1,279 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func creditLimitTestReader() *testReader {
+	errMsg := "oops sorry"
+	return &testReader{
+		rows: []Row{
+			{Name: "low", CreditLimit: "99.99"},
+			{Name: "min", CreditLimit: "100"},
+			{Name: "mid", CreditLimit: " 500.5 "},
+			{Name: "max", CreditLimit: "1000"},
+			{Name: "high", CreditLimit: "1000.01"},
+			{Name: "unknown", CreditLimit: "N/A"},
+			{ErrorMessage: &errMsg},
+		},
+	}
+}
+
+func TestHtmlFilterCreditLimit_Bounds_InclusiveRangeRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(creditLimitTestReader())
+	err := p.HTMLFilterCreditLimit(&buf, "name", 100, 1000)
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>min</td>")
+	assert.Contains(t, s, "<td>mid</td>")
+	assert.Contains(t, s, "<td>max</td>")
+	assert.NotContains(t, s, "<td>low</td>")
+	assert.NotContains(t, s, "<td>high</td>")
+	assert.NotContains(t, s, "unknown")
+	assert.Contains(t, s, `<td colspan="8">oops sorry</td>`)
+}
+
+func TestHtmlFilterCreditLimit_UnboundedMax_HighRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(creditLimitTestReader())
+	err := p.HTMLFilterCreditLimit(&buf, "name", 1000, math.Inf(1))
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>max</td>")
+	assert.Contains(t, s, "<td>high</td>")
+	assert.NotContains(t, s, "<td>mid</td>")
+}
+
+func TestHtmlFilterCreditLimit_ShowUnparseable_ErrorRowRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(creditLimitTestReader())
+	p.ShowUnparseableCreditLimits(true)
+	err := p.HTMLFilterCreditLimit(&buf, "name", math.Inf(-1), math.Inf(1))
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `<td colspan="8">Unparseable credit limit &#34;N/A&#34; of unknown</td>`)
+}
+
+func TestHtmlFilterCreditLimit_MinGreaterThanMax_ErrorReturned(t *testing.T) {
+	r := creditLimitTestReader()
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLFilterCreditLimit(&buf, "name", 1000, 100)
+
+	assert.EqualError(t, err, "Invalid credit limit range [1000, 100]")
+	assert.Len(t, buf.Bytes(), 0)
+	assert.Empty(t, r.readName)
+}
+
+func searchTestReader() *testReader {
+	errMsg := "oops sorry"
+	return &testReader{
+		rows: []Row{
+			{Name: "Stewart, Jamie", Postcode: "3123gg"},
+			{Name: "Leon, Mike", Postcode: "4532 AA"},
+			{ErrorMessage: &errMsg},
+		},
+	}
+}
+
+func TestHtmlSearch_QueryMatchesName_MatchingRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(searchTestReader())
+	err := p.HTMLSearch(&buf, "name", "JAMIE")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Stewart, Jamie</td>")
+	assert.NotContains(t, s, "<td>Leon, Mike</td>")
+	assert.Contains(t, s, `<td colspan="8">oops sorry</td>`)
+}
+
+func TestHtmlSearch_QueryMatchesPostcode_MatchingRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(searchTestReader())
+	err := p.HTMLSearch(&buf, "name", "32 a")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Leon, Mike</td>")
+	assert.NotContains(t, s, "<td>Stewart, Jamie</td>")
+}
+
+func TestHtmlSearch_QueryMatchesNothing_EmptyTableRendered(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie"}, {Name: "Leon, Mike"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLSearch(&buf, "name", "nobody")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<tr style="font-weight: Bold">`)
+	assert.Contains(t, s, "</table>")
+	assert.Contains(t, s, "</html>")
+	assert.NotContains(t, s, "<tr><td>")
+}
+
+func TestHtmlSearch_EmptyQuery_AllRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(searchTestReader())
+	err := p.HTMLSearch(&buf, "name", "")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Stewart, Jamie</td>")
+	assert.Contains(t, s, "<td>Leon, Mike</td>")
+}
+
+func TestHtmlDedupe_DuplicateNames_FirstOccurrenceRendered(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{
+		rows: []Row{
+			{Name: "Stewart, Jamie", Postcode: "3123gg"},
+			{Name: "Leon, Mike", Postcode: "4532 AA"},
+			{ErrorMessage: &errMsg},
+			{Name: "Stewart, Jamie", Postcode: "1000AB"},
+			{ErrorMessage: &errMsg},
+		},
+	}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLDedupe(&buf, "name", "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Equal(t, 1, strings.Count(s, "<td>Stewart, Jamie</td>"))
+	assert.Contains(t, s, "<td>3123gg</td>")
+	assert.NotContains(t, s, "<td>1000AB</td>")
+	assert.Equal(t, 1, strings.Count(s, "<td>Leon, Mike</td>"))
+	assert.Equal(t, 2, strings.Count(s, "oops sorry"))
+}
+
+func TestHtmlDedupe_NoDuplicates_AllRowsRendered(t *testing.T) {
+	r := &testReader{
+		rows: []Row{
+			{Name: "Stewart, Jamie", Postcode: "3123gg"},
+			{Name: "Stewart, Jamie", Postcode: "4532 AA"},
+		},
+	}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLDedupe(&buf, "name", "Postcode")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>3123gg</td>")
+	assert.Contains(t, s, "<td>4532 AA</td>")
+}
+
+func TestHtmlDedupe_UnknownKeyColumn_ErrorReturnedBeforeOutput(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLDedupe(&buf, "name", "Nickname")
+
+	assert.EqualError(t, err, "Unknown key column Nickname")
+	assert.Empty(t, buf.String())
+	assert.Empty(t, r.readName)
+}
+
+func birthdayTestReader() *testReader {
+	errMsg := "oops sorry"
+	return &testReader{
+		rows: []Row{
+			{Name: "early", Birthday: "1979-12-31"},
+			{Name: "from", Birthday: "1980-01-01"},
+			{Name: "mid", Birthday: " 1985-06-15 "},
+			{Name: "to", Birthday: "1990-12-31"},
+			{Name: "late", Birthday: "1991-01-01"},
+			{Name: "unknown", Birthday: "15/06/1985"},
+			{Name: "missing"},
+			{ErrorMessage: &errMsg},
+		},
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestHtmlFilterBirthday_Bounds_InclusiveRangeRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(birthdayTestReader())
+	// only dates of the bounds matter.
+	to := time.Date(1990, time.December, 31, 10, 30, 0, 0, time.UTC)
+	err := p.HTMLFilterBirthday(&buf, "name", date(1980, time.January, 1), to)
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>from</td>")
+	assert.Contains(t, s, "<td>mid</td>")
+	assert.Contains(t, s, "<td>to</td>")
+	assert.NotContains(t, s, "<td>early</td>")
+	assert.NotContains(t, s, "<td>late</td>")
+	assert.NotContains(t, s, "unknown")
+	assert.NotContains(t, s, "missing")
+	assert.Contains(t, s, "oops sorry")
+}
+
+func TestHtmlFilterBirthday_OpenEnds_UnboundedRangeRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(birthdayTestReader())
+	err := p.HTMLFilterBirthday(&buf, "name", time.Time{}, date(1980, time.January, 1))
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<td>early</td>")
+	assert.Contains(t, buf.String(), "<td>from</td>")
+	assert.NotContains(t, buf.String(), "<td>mid</td>")
+
+	buf.Reset()
+	err = p.HTMLFilterBirthday(&buf, "name", date(1990, time.December, 31), time.Time{})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<td>to</td>")
+	assert.Contains(t, buf.String(), "<td>late</td>")
+	assert.NotContains(t, buf.String(), "<td>mid</td>")
+}
+
+func TestHtmlFilterBirthday_ShowUnparseable_ErrorRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(birthdayTestReader())
+	p.ShowUnparseableBirthdays(true)
+	err := p.HTMLFilterBirthday(&buf, "name", time.Time{}, time.Time{})
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "Unparseable birthday &#34;15/06/1985&#34; of unknown")
+	assert.Contains(t, s, "Missing birthday of missing")
+	assert.Contains(t, s, "<td>mid</td>")
+}
+
+func TestHtmlFilterBirthday_FromAfterTo_ErrorReturned(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(birthdayTestReader())
+	err := p.HTMLFilterBirthday(&buf, "name", date(1990, time.January, 1), date(1980, time.January, 1))
+
+	assert.EqualError(t, err, "Invalid birthday range [1990-01-01, 1980-01-01]")
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,142 @@
+package spreadsheet
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonRow defines keys of JSON objects that represent rows.
+type jsonRow struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	Postcode    string `json:"postcode"`
+	Phone       string `json:"phone"`
+	CreditLimit string `json:"creditLimit"`
+	Birthday    string `json:"birthday"`
+	Email       string `json:"email"`
+	Company     string `json:"company"`
+}
+
+// jsonTypedRow defines keys of JSON objects that represent rows with
+// typed values. Values that can't be parsed are null.
+type jsonTypedRow struct {
+	Name        string   `json:"name"`
+	Address     string   `json:"address"`
+	Postcode    string   `json:"postcode"`
+	Phone       string   `json:"phone"`
+	CreditLimit *float64 `json:"creditLimit"`
+	Birthday    *string  `json:"birthday"`
+	Email       string   `json:"email"`
+	Company     string   `json:"company"`
+}
+
+// newJSONTypedRow converts the row to its typed JSON representation.
+func newJSONTypedRow(row Row) jsonTypedRow {
+	v := jsonTypedRow{
+		Name:     row.Name,
+		Address:  row.Address,
+		Postcode: row.Postcode,
+		Phone:    row.Phone,
+		Email:    row.Email,
+		Company:  row.Company,
+	}
+	// the pattern rules out values like NaN that JSON can't hold.
+	if limit := strings.TrimSpace(row.CreditLimit); creditLimitPattern.MatchString(limit) {
+		if f, err := strconv.ParseFloat(limit, 64); err == nil {
+			v.CreditLimit = &f
+		}
+	}
+	if birthday := strings.TrimSpace(row.Birthday); birthday != "" {
+		if _, err := time.Parse("2006-01-02", birthday); err == nil {
+			v.Birthday = &birthday
+		}
+	}
+	return v
+}
+
+// jsonError defines JSON object that represents an error row.
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// TypedJSON turns on typed values in JSON output. When enabled, credit
+// limit is a number and birthday is a date string in "2006-01-02" format.
+// Either is null if it's missing or can't be parsed. Otherwise, all values
+// are strings as they are read, which is the default.
+func (p *Producer) TypedJSON(enabled bool) {
+	p.typedJSON = enabled
+}
+
+// JSONL generates output that has a JSON object per line for every row of
+// spreadsheet. Error rows are output as objects with the only "error" key.
+// Values are typed if TypedJSON is on. If w is http.Flusher, it's flushed
+// after every row.
+func (p *Producer) JSONL(w io.Writer, name string) error {
+	if f, ok := w.(http.Flusher); ok {
+		w = &flushWriter{w: w, f: f, every: 1}
+	}
+
+	return p.run(context.Background(), name, nil, "Encoder", func(rows <-chan Row, stop func()) error {
+		enc := json.NewEncoder(w)
+		for row := range rows {
+			var v interface{}
+			if row.ErrorMessage != nil {
+				v = jsonError{Error: *row.ErrorMessage}
+			} else if p.typedJSON {
+				v = newJSONTypedRow(row)
+			} else {
+				v = jsonRow{
+					Name:        row.Name,
+					Address:     row.Address,
+					Postcode:    row.Postcode,
+					Phone:       row.Phone,
+					CreditLimit: row.CreditLimit,
+					Birthday:    row.Birthday,
+					Email:       row.Email,
+					Company:     row.Company,
+				}
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// JSONLProducer provides JSONL output of Producer through the plugin
+// interface of HTML producers, e.g. to serve it over HTTP.
+type JSONLProducer struct {
+	p *Producer
+}
+
+// NewJSONLProducer creates and initializes a new instance of JSONLProducer.
+func NewJSONLProducer(reader Reader) *JSONLProducer {
+	return &JSONLProducer{p: NewProducer(reader)}
+}
+
+// TypedJSON turns on typed values in output, see Producer.TypedJSON.
+func (jp *JSONLProducer) TypedJSON(enabled bool) {
+	jp.p.TypedJSON(enabled)
+}
+
+// HTML generates JSONL output despite its name, see Producer.JSONL.
+func (jp *JSONLProducer) HTML(w io.Writer, name string) error {
+	return jp.p.JSONL(w, name)
+}
+
+// ContentType returns the media type of JSON Lines.
+func (jp *JSONLProducer) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// ModTime returns modification time of the spreadsheet with the specified
+// name if the reader is a ModTimer, otherwise false is returned.
+func (jp *JSONLProducer) ModTime(name string) (time.Time, bool) {
+	return jp.p.ModTime(name)
+}
@@ -0,0 +1,129 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonl_SuccessfulRead_ObjectPerLine(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+		{ErrorMessage: &errMsg},
+		{Name: "Leon, Mike", CreditLimit: "201092"},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.JSONL(&buf, "name")
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	var objs []map[string]string
+	for _, line := range lines {
+		var obj map[string]string
+		assert.NoError(t, json.Unmarshal([]byte(line), &obj), line)
+		objs = append(objs, obj)
+	}
+	assert.Equal(t, "Stewart, Jamie", objs[0]["name"])
+	assert.Equal(t, "3123gg", objs[0]["postcode"])
+	assert.Equal(t, map[string]string{"error": "oops sorry"}, objs[1])
+	assert.Equal(t, "201092", objs[2]["creditLimit"])
+}
+
+func TestJsonl_ReadError_ErrorReturnedAndNothingWritten(t *testing.T) {
+	r := &testReader{err: errors.New("must read, but won't")}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.JSONL(&buf, "name")
+
+	assert.EqualError(t, err, "must read, but won't")
+	assert.Empty(t, buf.String())
+}
+
+func TestJsonl_ReadPanic_ErrorReturned(t *testing.T) {
+	r := &testReader{panic: "something went wrong"}
+
+	p := NewProducer(r)
+	err := p.JSONL(&bytes.Buffer{}, "name1")
+
+	assert.EqualError(t, err, "Reader *spreadsheet.testReader paniced on name1: something went wrong")
+}
+
+func TestJsonl_FlusherWriter_FlushedPerRow(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "name1"}, {Name: "name2"}, {Name: "name3"}}}
+	w := &flushCountingWriter{}
+
+	p := NewProducer(r)
+	err := p.JSONL(w, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, w.flushes)
+}
+
+func TestJsonlProducer_Html_JsonlWritten(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewJSONLProducer(&testReader{rows: []Row{{Name: "Stewart, Jamie"}}})
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(buf.String(), `{"name":"Stewart, Jamie",`))
+	assert.Equal(t, "application/x-ndjson", p.ContentType())
+}
+
+func TestJsonlTyped_CreditLimitAndBirthday_TypedValues(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", CreditLimit: " 10000.5 ", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", CreditLimit: "a lot", Birthday: "15/01/1975"},
+		{Name: "Kling, Jeramie", CreditLimit: "NaN"},
+		{ErrorMessage: &errMsg},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	p.TypedJSON(true)
+	err := p.JSONL(&buf, "name")
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Contains(t, lines[0], `"creditLimit":10000.5,"birthday":"1982-02-01"`)
+		assert.Contains(t, lines[1], `"creditLimit":null,"birthday":null`)
+		assert.Contains(t, lines[2], `"creditLimit":null,"birthday":null`)
+		assert.Equal(t, `{"error":"oops sorry"}`, lines[3])
+	}
+}
+
+func TestJsonl_TypedJSONOff_AllValuesStrings(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie", CreditLimit: "10000"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.JSONL(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `"creditLimit":"10000","birthday":""`)
+}
+
+func TestJSONLProducerTyped_CreditLimit_NumberWritten(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie", CreditLimit: "10000"}}}
+	var buf bytes.Buffer
+
+	jp := NewJSONLProducer(r)
+	jp.TypedJSON(true)
+	err := jp.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `"creditLimit":10000,`)
+}
@@ -0,0 +1,55 @@
+package spreadsheet
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// HTMLLimit generates output to display at most limit first rows of
+// spreadsheet as a web page. Error rows count toward the limit. Read is
+// stopped once the limit is reached. Zero limit means no limit.
+func (p *Producer) HTMLLimit(w io.Writer, name string, limit int) error {
+	return p.HTMLLimitContext(context.Background(), w, name, limit)
+}
+
+// HTMLLimitContext acts as HTMLLimit but respects cancellation of ctx
+// the same way as HTMLContext does.
+func (p *Producer) HTMLLimitContext(ctx context.Context, w io.Writer, name string, limit int) error {
+	if limit <= 0 {
+		return p.HTMLContext(ctx, w, name)
+	}
+	if f, ok := w.(http.Flusher); ok {
+		w = &flushWriter{w: w, f: f, every: flushLines}
+	}
+
+	return p.run(ctx, name, nil, "Template", func(rows <-chan Row, stop func()) error {
+		limited := limitRows(rows, limit, stop)
+		defer func() {
+			stop()
+			for _ = range limited {
+				// limitRows finishes once counting is stopped
+			}
+		}()
+		return p.execute(w, name, nil, p.htmlTemplate, limited, stop)
+	})
+}
+
+// limitRows relays at most limit rows and stops the read afterwards.
+// Rows are received one by one after the previous one is relayed, so
+// no row is read beyond the limit.
+func limitRows(rows <-chan Row, limit int, stop func()) <-chan Row {
+	out := make(chan Row)
+	go func() {
+		defer close(out)
+		for i := 0; i < limit; i++ {
+			row, ok := <-rows
+			if !ok {
+				return
+			}
+			out <- row
+		}
+		stop()
+	}()
+	return out
+}
@@ -0,0 +1,63 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func limitTestReader(n int) *testReader {
+	r := &testReader{rows: make([]Row, n)}
+	for i := range r.rows {
+		r.rows[i].Name = fmt.Sprintf("row%d", i)
+	}
+	return r
+}
+
+func TestHtmlLimit_MoreRowsThanLimit_LimitedRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+	var reported []ReadStats
+
+	p := NewProducer(limitTestReader(10))
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	err := p.HTMLLimit(&buf, "name", 3)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, strings.Count(buf.String(), "<td>row"))
+	assert.Contains(t, buf.String(), "<td>row2</td>")
+	assert.Contains(t, buf.String(), "</html>")
+	assert.Equal(t, []ReadStats{{Name: "name", Rows: 3, Stopped: true}}, reported)
+}
+
+func TestHtmlLimit_FewerRowsThanLimit_AllRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(limitTestReader(2))
+	err := p.HTMLLimit(&buf, "name", 5)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, strings.Count(buf.String(), "<td>row"))
+}
+
+func TestHtmlLimit_ZeroLimit_AllRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(limitTestReader(4))
+	err := p.HTMLLimit(&buf, "name", 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4, strings.Count(buf.String(), "<td>row"))
+}
+
+func TestHtmlLimit_PrintProducer_LimitedRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(limitTestReader(10), 2)
+	err := p.HTMLLimit(&buf, "name", 5)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, strings.Count(buf.String(), "<td>row"))
+}
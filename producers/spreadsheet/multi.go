@@ -0,0 +1,127 @@
+package spreadsheet
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// multiReader reads the same spreadsheet from several readers
+// and provides their rows as a single spreadsheet.
+type multiReader []Reader
+
+// NewMultiProducer creates and initializes a new instance of spreadsheet
+// Producer which output merges spreadsheets read by all the specified
+// readers. Rows are provided source by source in the order of readers.
+// If any reader fails to confirm the read, errors of all failed readers
+// are combined and nothing is output.
+func NewMultiProducer(readers ...Reader) *Producer {
+	return NewProducer(multiReader(readers))
+}
+
+// subRead holds channels of a single reader run by multiReader.
+type subRead struct {
+	confirm chan error
+	rows    chan Row
+	panic   interface{}
+}
+
+func (mr multiReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	subStop := make(chan struct{})
+	var stopOnce sync.Once
+	stopAll := func() {
+		stopOnce.Do(func() { close(subStop) })
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-stop:
+			stopAll()
+		case <-finished:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	subs := make([]*subRead, len(mr))
+	for i, r := range mr {
+		sub := &subRead{
+			confirm: make(chan error, 1),
+			rows:    make(chan Row),
+		}
+		subs[i] = sub
+		wg.Add(1)
+		go func(r Reader) {
+			defer wg.Done()
+			defer func() {
+				// panics can't be recovered by Producer in this goroutine,
+				// so they are passed to Read's goroutine.
+				sub.panic = recover()
+				close(sub.rows)
+				close(sub.confirm)
+			}()
+			r.Read(name, sub.confirm, sub.rows, subStop)
+		}(r)
+	}
+
+	// drainAll lets all readers finish and re-panics if any of them paniced.
+	drainAll := func() {
+		stopAll()
+		for _, sub := range subs {
+			for _ = range sub.rows {
+				// allow reader to finish gracefully
+			}
+		}
+		wg.Wait()
+		for i, sub := range subs {
+			if sub.panic != nil {
+				panic(fmt.Sprintf("%T: %v", mr[i], sub.panic))
+			}
+		}
+	}
+	defer drainAll()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := <-sub.confirm; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		stopAll()
+		confirm <- errors.Join(errs...)
+		return
+	}
+	confirm <- nil
+
+	for _, sub := range subs {
+		for row := range sub.rows {
+			select {
+			case rows <- row:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// ModTime returns the latest modification time among all readers.
+// If time of any reader is unknown, false is returned.
+func (mr multiReader) ModTime(name string) (time.Time, bool) {
+	var latest time.Time
+	for _, r := range mr {
+		mt, ok := r.(ModTimer)
+		if !ok {
+			return time.Time{}, false
+		}
+		t, ok := mt.ModTime(name)
+		if !ok {
+			return time.Time{}, false
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest, len(mr) > 0
+}
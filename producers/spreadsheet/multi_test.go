@@ -0,0 +1,109 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiHtml_TwoReaders_RowsMergedSourceBySource(t *testing.T) {
+	r1 := testReader{rows: []Row{{Name: "name1"}, {Name: "name2"}}}
+	r2 := testReader{rows: []Row{{Name: "name3"}}}
+	var buf bytes.Buffer
+
+	p := NewMultiProducer(&r1, &r2)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Equal(t, "name", r1.readName)
+	assert.Equal(t, "name", r2.readName)
+	i1 := strings.Index(s, "<td>name1</td>")
+	i2 := strings.Index(s, "<td>name2</td>")
+	i3 := strings.Index(s, "<td>name3</td>")
+	assert.True(t, i1 >= 0 && i1 < i2 && i2 < i3, "rows are not in order: %s", s)
+}
+
+func TestMultiHtml_OneReaderConfirmError_ErrorReturned(t *testing.T) {
+	r1 := testReader{rows: []Row{{Name: "name1"}}}
+	r2 := testReader{err: os.ErrNotExist}
+	var buf bytes.Buffer
+
+	p := NewMultiProducer(&r1, &r2)
+	err := p.HTML(&buf, "name")
+
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Len(t, buf.Bytes(), 0)
+}
+
+func TestMultiHtml_SeveralReadersConfirmError_ErrorsCombined(t *testing.T) {
+	r1 := testReader{err: errors.New("err1")}
+	r2 := testReader{err: errors.New("err2")}
+
+	p := NewMultiProducer(&r1, &r2)
+	err := p.HTML(&bytes.Buffer{}, "name")
+
+	assert.EqualError(t, err, "err1\nerr2")
+}
+
+func TestMultiHtml_ReaderPanic_ErrorReturned(t *testing.T) {
+	r1 := testReader{rows: []Row{{Name: "name1"}}}
+	r2 := testReader{panic: "something went wrong"}
+
+	p := NewMultiProducer(&r1, &r2)
+	err := p.HTML(&bytes.Buffer{}, "name1")
+
+	assert.EqualError(t, err, "Reader spreadsheet.multiReader paniced on name1: *spreadsheet.testReader: something went wrong")
+}
+
+func TestMultiHtml_OutputFailed_ReadersStopped(t *testing.T) {
+	r1 := testReader{rows: make([]Row, 10)}
+	r2 := testReader{rows: make([]Row, 10)}
+	for i := range r1.rows {
+		r1.rows[i].Name = "first"
+		r2.rows[i].Name = "second"
+	}
+
+	p := NewMultiProducer(&r1, &r2)
+	err := p.HTML(failingWriter{failOn: "first"}, "name")
+
+	assert.EqualError(t, err, "connection reset")
+}
+
+type modTimeReader struct {
+	testReader
+	modTime time.Time
+	known   bool
+}
+
+func (r *modTimeReader) ModTime(name string) (time.Time, bool) {
+	return r.modTime, r.known
+}
+
+func TestMultiProducerModTime_AllKnown_LatestReturned(t *testing.T) {
+	older := time.Date(2017, 10, 8, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	p := NewMultiProducer(
+		&modTimeReader{modTime: older, known: true},
+		&modTimeReader{modTime: newer, known: true})
+
+	modTime, ok := p.ModTime("name")
+
+	assert.True(t, ok)
+	assert.Equal(t, newer, modTime)
+}
+
+func TestMultiProducerModTime_SomeUnknown_FalseReturned(t *testing.T) {
+	p := NewMultiProducer(
+		&modTimeReader{modTime: time.Now(), known: true},
+		&testReader{})
+
+	_, ok := p.ModTime("name")
+
+	assert.False(t, ok)
+}
@@ -1,9 +1,19 @@
 package spreadsheet
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Reader stands as a data source for spreadsheet Producer.
@@ -17,6 +27,31 @@ type Reader interface {
 	Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{})
 }
 
+// ModTimer is an optional interface implemented by Readers that know
+// when a spreadsheet was modified last time.
+type ModTimer interface {
+	// ModTime returns modification time of the spreadsheet with
+	// a given name. If the time is unknown, false is returned.
+	ModTime(name string) (time.Time, bool)
+}
+
+// Prober is an optional interface implemented by Readers that are able
+// to check whether their storage is reachable.
+type Prober interface {
+	// Probe returns an error if spreadsheets can't be read at all,
+	// e.g. because a data directory is missing.
+	Probe() error
+}
+
+// LayoutReader is an optional interface implemented by Readers that are
+// able to tell how they interpret the header of a spreadsheet.
+type LayoutReader interface {
+	// Layout returns the layout detected in the spreadsheet with
+	// a given name without reading its rows. The layout must be
+	// suitable for JSON encoding.
+	Layout(name string) (interface{}, error)
+}
+
 // Row represents a row in a spreadsheet. Readers must set
 // error message if row read is failed.
 type Row struct {
@@ -26,107 +61,811 @@ type Row struct {
 	Phone        string
 	CreditLimit  string
 	Birthday     string
+	Email        string
+	Company      string
 	ErrorMessage *string
+	// Extra holds values of unknown columns in the order of the
+	// spreadsheet. Readers set it only if asked to keep such columns.
+	// Error rows may hold the fields with blank values.
+	Extra []Field
+}
+
+// Field is a value of a column unknown to Producer.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// fields returns values of all data fields of the row.
+func (row Row) fields() []string {
+	values := make([]string, len(Columns))
+	for i, col := range Columns {
+		values[i] = col.Get(row)
+	}
+	return values
+}
+
+// Age returns the number of full years passed since birthday till the
+// specified time. Blank string is returned if birthday is missing, isn't
+// in "2006-01-02" format or is after the time.
+func (row Row) Age(now time.Time) string {
+	birthday, err := time.Parse("2006-01-02", strings.TrimSpace(row.Birthday))
+	if err != nil {
+		return ""
+	}
+	age := now.Year() - birthday.Year()
+	if now.Month() < birthday.Month() || now.Month() == birthday.Month() && now.Day() < birthday.Day() {
+		age--
+	}
+	if age < 0 {
+		return ""
+	}
+	return strconv.Itoa(age)
+}
+
+// creditLimitPattern matches a plain decimal number.
+var creditLimitPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// CreditLimitValid reports whether the credit limit is a number.
+// Empty credit limit is considered valid since it's just missing.
+func (row Row) CreditLimitValid() bool {
+	v := strings.TrimSpace(row.CreditLimit)
+	return v == "" || creditLimitPattern.MatchString(v)
+}
+
+// maxPreviewLen limits number of runes of a raw record
+// previewed in an error message of invalid row.
+const maxPreviewLen = 20
+
+// InvalidRow creates a Row reporting that the specified line can't be read.
+// The error message includes a preview of the raw record if it's known.
+// The preview is truncated to keep the message length bounded.
+func InvalidRow(line int, raw string) Row {
+	msg := fmt.Sprintf("Invalid row %d", line)
+	raw = strings.TrimRight(raw, "\r\n")
+	if raw != "" {
+		if runes := []rune(raw); len(runes) > maxPreviewLen {
+			raw = string(runes[:maxPreviewLen]) + "..."
+		}
+		msg = fmt.Sprintf("%s: %q", msg, raw)
+	}
+	return Row{ErrorMessage: &msg}
 }
 
 const (
+	templateRows = `
+{{define "header"}}<tr style="font-weight: Bold"{{if interactive}} class="sortable"{{end}}>{{if shown "Name"}}<td>Name</td>{{end}}{{if shown "Address"}}<td>Address</td>{{end}}{{if shown "Postcode"}}<td>Postcode</td>{{end}}{{if shown "Phone"}}<td>Phone</td>{{end}}{{if shown "Credit Limit"}}<td>Credit Limit</td>{{end}}{{if shown "Birthday"}}<td>Birthday</td>{{end}}{{if shown "Email"}}<td>Email</td>{{end}}{{if shown "Company"}}<td>Company</td>{{end}}{{if showExtra}}{{range .}}<td>{{.Name}}</td>{{end}}{{end}}{{if showAge}}<td>Age</td>{{end}}</tr>{{end}}
+{{define "row"}}{{if not .ErrorMessage}}<tr>{{if shown "Name"}}<td>{{.Name}}</td>{{end}}{{if shown "Address"}}<td>{{.Address}}</td>{{end}}{{if shown "Postcode"}}<td>{{.Postcode}}</td>{{end}}{{if shown "Phone"}}<td>{{.Phone}}</td>{{end}}{{if shown "Credit Limit"}}<td align="right"{{if and validateCreditLimit (not .CreditLimitValid)}} style="color: red"{{end}}>{{.CreditLimit}}</td>{{end}}{{if shown "Birthday"}}<td align="right">{{.Birthday}}</td>{{end}}{{if shown "Email"}}<td>{{.Email}}</td>{{end}}{{if shown "Company"}}<td>{{.Company}}</td>{{end}}{{if showExtra}}{{range .Extra}}<td>{{.Value}}</td>{{end}}{{end}}{{if showAge}}<td align="right">{{age .}}</td>{{end}}</tr>{{else}}<tr{{with errorClass}} class="{{.}}"{{end}}><td colspan="{{colspan .}}">{{if errorClass}}Error: {{end}}{{.ErrorMessage}}</td></tr>{{end}}{{end}}
+{{define "style"}}{{with errorClass}}<style>.{{.}} { background-color: #fcc; }</style>{{end}}{{end}}
+{{define "script"}}{{if interactive}}
+		<script>
+			document.querySelectorAll("tr.sortable").forEach(function (header) {
+				var section = header.parentNode;
+				Array.prototype.forEach.call(header.cells, function (cell, index) {
+					var ascending = true;
+					cell.style.cursor = "pointer";
+					cell.addEventListener("click", function () {
+						var rows = Array.prototype.slice.call(section.rows, header.sectionRowIndex + 1);
+						var text = function (row) {
+							return row.cells.length > index ? row.cells[index].textContent.trim() : "";
+						};
+						rows.sort(function (a, b) {
+							var x = text(a), y = text(b);
+							var nx = parseFloat(x), ny = parseFloat(y);
+							var order = isNaN(nx) || isNaN(ny) ? x.localeCompare(y) : nx - ny;
+							return ascending ? order : -order;
+						});
+						ascending = !ascending;
+						rows.forEach(function (row) {
+							section.appendChild(row);
+						});
+					});
+				});
+			});
+		</script>{{end}}{{end}}`
+
 	templateBody = `
 <!DOCTYPE html>
 <html>
 	<head>
 		<meta charset="UTF-8">
-		<title>{{.Title}}</title>
+		<title>{{.Title}}</title>{{template "style"}}
+	</head>
+	<body>{{with .Heading}}
+		<h1>{{.}}</h1>{{end}}
+		{{if .Empty}}<p>No records found</p>{{else}}{{with .Omitted}}
+		<p>{{.}} extra columns are not shown since there are too many columns</p>{{end}}
+		<table style="font-family:Courier New, Courier, monospace; white-space:pre">
+			{{template "header" .Extra}}
+			{{with .First}}{{template "row" .}}
+			{{end}}{{range .Rows}}{{template "row" .}}
+			{{end}}
+		</table>{{end}}{{template "script"}}
+	</body>
+</html>`
+
+	templatePrintBody = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>{{.Title}}</title>{{template "style"}}
 	</head>
-	<body>
+	<body>{{with .Heading}}
+		<h1>{{.}}</h1>{{end}}
+		{{if .Empty}}<p>No records found</p>{{end}}{{with .Omitted}}
+		<p>{{.}} extra columns are not shown since there are too many columns</p>{{end}}
+		{{range .Pages}}{{if .Index}}<div style="page-break-after: always"></div>{{end}}
 		<table style="font-family:Courier New, Courier, monospace; white-space:pre">
-			<tr style="font-weight: Bold"><td>Name</td><td>Address</td><td>Postcode</td><td>Phone</td><td>Credit Limit</td><td>Birthday</td></tr>
-			{{range .Rows}}<tr>{{if not .ErrorMessage}}<td>{{.Name}}</td><td>{{.Address}}</td><td>{{.Postcode}}</td><td>{{.Phone}}</td><td align="right">{{.CreditLimit}}</td><td align="right">{{.Birthday}}</td>{{else}}<td colspan="6">{{.ErrorMessage}}</td>{{end}}</tr>{{end}}
+			{{template "header" $.Extra}}
+			{{range .Rows}}{{template "row" .}}
+			{{end}}
 		</table>
+		{{end}}{{template "script"}}
 	</body>
 </html>`
 )
 
 // templateData provides data for spreadsheet HTML template.
+// Either Rows or Pages is set depending on whether output is paginated.
+// Rows is preceded by First if it was read to find out whether
+// there are any rows. Neither is set if Empty is true. Extra
+// columns are taken from the first row. Omitted is the number of extra
+// columns that aren't rendered since there are too many columns. Heading
+// is set only if the title is customized.
 type templateData struct {
-	Title string
-	Rows  <-chan Row
+	Title   string
+	Heading string
+	Empty   bool
+	Extra   []Field
+	Omitted int
+	First   *Row
+	Rows    <-chan Row
+	Pages   <-chan page
+}
+
+// page is a chunk of rows printed on a separate sheet.
+type page struct {
+	Index int
+	Rows  []Row
 }
 
+// ReadStats describes how far a read went before it was finished.
+type ReadStats struct {
+	// Name is the name of the spreadsheet.
+	Name string
+	// Rows is the number of rows passed to the output.
+	Rows int
+	// Stopped is true if the read was stopped before the reader
+	// finished, e.g. because of a failed output, a limit or a done
+	// context. Otherwise the reader provided all of its rows, even
+	// if not all of them were passed to the output.
+	Stopped bool
+}
+
+// ErrReadTimeout is returned when a reader doesn't confirm read
+// within the timeout of Producer created by NewProducerWithTimeout.
+var ErrReadTimeout = errors.New("Read timed out")
+
 // Producer provides solutions for spreadsheet output.
 type Producer struct {
-	reader       Reader
-	htmlTemplate *template.Template
+	reader         Reader
+	body           string
+	funcs          template.FuncMap
+	htmlTemplate   *template.Template
+	ageTemplate    *template.Template
+	now            func() time.Time
+	pageSize       int
+	statsFunc      func(ReadStats)
+	title          func(name string) string
+	transforms     []Transform
+	confirmTimeout time.Duration
+
+	validateCreditLimit      bool
+	typedJSON                bool
+	byteOrderMark            bool
+	showUnparseable          bool
+	showUnparseableBirthdays bool
+	showExtra                bool
+	interactive              bool
+	maxErrorRows             int
+	maxColumns               int
+	errorClass               string
 }
 
 // NewProducer creates and initializes a new instance of spreadsheet Producer.
 func NewProducer(reader Reader) *Producer {
-	return &Producer{
-		reader:       reader,
-		htmlTemplate: template.Must(template.New("spreadsheet").Parse(templateBody)),
+	p := &Producer{reader: reader, now: time.Now, maxColumns: defaultMaxColumns}
+	p.setBody(templateBody)
+	return p
+}
+
+// NewProducerWithTimeout creates and initializes a new instance of
+// spreadsheet Producer that abandons read if the reader doesn't confirm
+// it within the specified timeout, e.g. because a loader hangs. In this
+// case an error wrapping ErrReadTimeout is returned. The reader is left
+// running on its own and whatever it provides later is discarded.
+func NewProducerWithTimeout(reader Reader, timeout time.Duration) *Producer {
+	if timeout <= 0 {
+		panic(fmt.Sprintf("Invalid timeout %s", timeout))
 	}
+	p := NewProducer(reader)
+	p.confirmTimeout = timeout
+	return p
+}
+
+// NewPrintProducer creates and initializes a new instance of spreadsheet
+// Producer which HTML output is suitable for printing. A page break is
+// inserted after each pageSize rows and the header is repeated on every page.
+func NewPrintProducer(reader Reader, pageSize int) *Producer {
+	if pageSize <= 0 {
+		panic(fmt.Sprintf("Invalid page size %d", pageSize))
+	}
+	p := NewProducer(reader)
+	p.pageSize = pageSize
+	p.setBody(templatePrintBody)
+	return p
+}
+
+// ReportStats sets a function that is called with statistics of every
+// read once the output is produced. Reads that are stopped early are
+// logged regardless of this function.
+func (p *Producer) ReportStats(fn func(ReadStats)) {
+	p.statsFunc = fn
+}
+
+// ValidateCreditLimit turns on validation of credit limits. When enabled,
+// credit limits that aren't numbers are highlighted in the output.
+func (p *Producer) ValidateCreditLimit(enabled bool) {
+	p.validateCreditLimit = enabled
+}
+
+// HighlightErrorRows makes error rows stand out in the output. Error rows
+// are rendered with the specified CSS class which gives them a red
+// background, and their messages are prefixed with "Error:". Empty class
+// turns highlighting off which is the default.
+func (p *Producer) HighlightErrorRows(class string) {
+	p.errorClass = class
+}
+
+// ShowExtraColumns turns on output of unknown columns that readers keep
+// in Row.Extra. The columns are rendered after the known ones with their
+// original headers taken from the first row.
+func (p *Producer) ShowExtraColumns(enabled bool) {
+	p.showExtra = enabled
+}
+
+// HTMLInteractive turns on sorting of HTML output by clicking column
+// headers. Sorting is done by a script embedded in the output, so it
+// works only for rows that have been rendered, e.g. within a page of
+// printable output.
+func (p *Producer) HTMLInteractive(enabled bool) {
+	p.interactive = enabled
+}
+
+// LimitErrorRows sets the maximum number of error rows passed to the
+// output. Once there are more of them, the read is stopped and a final
+// error row tells that there are too many errors. Zero or negative max
+// means no limit which is the default.
+func (p *Producer) LimitErrorRows(max int) {
+	p.maxErrorRows = max
+}
+
+// defaultMaxColumns is the default limit of columns rendered
+// along with extra ones.
+const defaultMaxColumns = 64
+
+// LimitColumns sets the maximum number of columns rendered when extra
+// columns are shown. If known and extra columns together exceed it, only
+// known columns are rendered along with a note about omitted ones. It
+// protects both the server and browsers from files with enormous headers.
+// Zero or negative max means no limit. The default is 64.
+func (p *Producer) LimitColumns(max int) {
+	p.maxColumns = max
+}
+
+// SetTitle sets the function that makes the page title of the spreadsheet
+// with a given name, e.g. to turn "customers" into "Report: customers".
+// The title is also shown as a heading. By default the name itself is
+// the title and there is no heading.
+func (p *Producer) SetTitle(title func(name string) string) {
+	p.title = title
+}
+
+// SetTitleFormat acts as SetTitle with a function that formats the
+// name according to the specified format, e.g. "Report: %s".
+func (p *Producer) SetTitleFormat(format string) {
+	p.SetTitle(func(name string) string { return fmt.Sprintf(format, name) })
+}
+
+// SetClock sets the function that tells current time, e.g. to compute
+// ages. By default time.Now is used.
+func (p *Producer) SetClock(now func() time.Time) {
+	p.now = now
+}
+
+// setBody makes the specified template body used for output.
+func (p *Producer) setBody(body string) {
+	p.body = body
+	p.htmlTemplate = p.parseTemplate(body, false, nil)
+	p.ageTemplate = p.parseTemplate(body, true, nil)
+}
+
+// parseTemplate parses the specified body along with templates of rows.
+// Columns which names are in hidden map are omitted.
+func (p *Producer) parseTemplate(body string, showAge bool, hidden map[string]bool) *template.Template {
+	funcs := template.FuncMap{
+		"validateCreditLimit": func() bool { return p.validateCreditLimit },
+		"errorClass":          func() string { return p.errorClass },
+		"showAge":             func() bool { return showAge },
+		"showExtra":           func() bool { return p.showExtra },
+		"interactive":         func() bool { return p.interactive },
+		"shown":               func(col string) bool { return !hidden[col] },
+		"age":                 func(row Row) string { return row.Age(p.now()) },
+		"colspan": func(row Row) int {
+			n := len(Columns) - len(hidden)
+			if showAge {
+				n++
+			}
+			if p.showExtra {
+				n += len(row.Extra)
+			}
+			return n
+		},
+	}
+	// Functions of the default templates are added last to take precedence.
+	t := template.New("spreadsheet").Funcs(HelperFuncs).Funcs(p.funcs).Funcs(funcs)
+	t = template.Must(t.Parse(templateRows))
+	return template.Must(t.Parse(body))
 }
 
 // HTML generates output to display spreadsheet as a web page.
 func (p *Producer) HTML(w io.Writer, name string) error {
-	done := make(chan error, 2)
-	doneIfPanic := func(helper string) {
+	return p.HTMLContext(context.Background(), w, name)
+}
+
+// HTMLWithAge generates output to display spreadsheet as a web page with
+// an extra column that shows age computed from birthday.
+func (p *Producer) HTMLWithAge(w io.Writer, name string) error {
+	return p.render(context.Background(), w, name, nil, p.ageTemplate)
+}
+
+// HTMLContext generates output to display spreadsheet as a web page.
+// Once ctx is done, read is stopped and the context's error is returned.
+// If w is http.Flusher, it's flushed periodically to render rows progressively.
+func (p *Producer) HTMLContext(ctx context.Context, w io.Writer, name string) error {
+	return p.html(ctx, w, name, nil)
+}
+
+// ModTime returns modification time of the spreadsheet with the specified
+// name if the reader is a ModTimer, otherwise false is returned.
+func (p *Producer) ModTime(name string) (time.Time, bool) {
+	if mt, ok := p.reader.(ModTimer); ok {
+		return mt.ModTime(name)
+	}
+	return time.Time{}, false
+}
+
+// Probe checks whether storage of the reader is reachable if the reader
+// is a Prober, otherwise an error wrapping errors.ErrUnsupported is
+// returned.
+func (p *Producer) Probe() error {
+	pr, ok := p.reader.(Prober)
+	if !ok {
+		return fmt.Errorf("Reader %T can't be probed: %w", p.reader, errors.ErrUnsupported)
+	}
+	return pr.Probe()
+}
+
+// Layout returns the layout detected in the spreadsheet with the specified
+// name if the reader is a LayoutReader, otherwise an error wrapping
+// errors.ErrUnsupported is returned.
+func (p *Producer) Layout(name string) (interface{}, error) {
+	if lr, ok := p.reader.(LayoutReader); ok {
+		return lr.Layout(name)
+	}
+	return nil, fmt.Errorf("Reader %T doesn't report layout: %w", p.reader, errors.ErrUnsupported)
+}
+
+// html generates HTML output of rows accepted by the specified filter.
+// Filter may replace a row, e.g. by an error row. If filter is nil,
+// all rows are output.
+func (p *Producer) html(ctx context.Context, w io.Writer, name string, filter rowFilter) error {
+	return p.render(ctx, w, name, filter, p.htmlTemplate)
+}
+
+// render acts as html but executes the specified template.
+func (p *Producer) render(ctx context.Context, w io.Writer, name string, filter rowFilter, t *template.Template) error {
+	if f, ok := w.(http.Flusher); ok {
+		w = &flushWriter{w: w, f: f, every: flushLines}
+	}
+
+	return p.run(ctx, name, filter, "Template", func(rows <-chan Row, stop func()) error {
+		return p.execute(w, name, filter, t, rows, stop)
+	})
+}
+
+// execute executes the specified template over rows provided by run.
+func (p *Producer) execute(w io.Writer, name string, filter rowFilter, t *template.Template, rows <-chan Row, stop func()) error {
+	data := templateData{Title: name}
+	if p.title != nil {
+		data.Title = p.title(name)
+		data.Heading = data.Title
+	}
+	var first *Row
+	if filter == nil || p.showExtra {
+		// filtered output keeps an empty table which
+		// shows that nothing matched the filter.
+		row, ok := <-rows
+		if !ok && filter == nil {
+			data.Empty = true
+			return t.Execute(w, data)
+		}
+		if ok {
+			first = &row
+			if p.showExtra {
+				data.Extra = row.Extra
+			}
+			if p.showExtra && p.maxColumns > 0 && len(Columns)+len(row.Extra) > p.maxColumns {
+				// readers keep the same extra columns in every row,
+				// so the first one tells about all of them.
+				data.Extra, data.Omitted = nil, len(row.Extra)
+				first.Extra = nil
+				rows = dropExtra(rows)
+			}
+		}
+	}
+	if p.pageSize > 0 {
+		data.Pages = paginate(first, rows, p.pageSize)
+	} else {
+		data.First = first
+		data.Rows = rows
+	}
+
+	defer func() {
+		stop()
+		if data.Pages != nil {
+			for _ = range data.Pages {
+				// paginate finishes once counting is stopped
+			}
+		}
+		if data.Omitted > 0 {
+			for _ = range rows {
+				// dropExtra finishes once counting is stopped
+			}
+		}
+	}()
+	return t.Execute(w, data)
+}
+
+// Rows reads the spreadsheet with the specified name and provides its
+// rows through the returned channel without producing any output. Once
+// the rows channel is closed, the result of read is sent to the error
+// channel: an error of accessing the spreadsheet, of a reader's panic,
+// or nil. The rows channel must be drained.
+func (p *Producer) Rows(name string) (<-chan Row, <-chan error) {
+	return p.RowsContext(context.Background(), name)
+}
+
+// RowsContext acts as Rows but stops reading once ctx is done. In this
+// case the rows channel is closed and the context's error is sent.
+func (p *Producer) RowsContext(ctx context.Context, name string) (<-chan Row, <-chan error) {
+	out := make(chan Row)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := p.run(ctx, name, nil, "Consumer", func(rows <-chan Row, stop func()) error {
+			for row := range rows {
+				select {
+				case out <- row:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return nil
+		})
+		close(out)
+		errc <- err
+	}()
+	return out, errc
+}
+
+// consumeFunc consumes rows of a read. Calling stop makes the read finish
+// and rows channel closed, which allows to quit consuming early.
+type consumeFunc func(rows <-chan Row, stop func()) error
+
+// run reads the spreadsheet with the specified name and passes its rows
+// accepted by the filter to consume. Panics of both the reader and
+// consume are returned as errors prefixed with the specified consumer
+// name. Once ctx is done, read is stopped and the context's error is
+// returned.
+func (p *Producer) run(ctx context.Context, name string, filter rowFilter, consumer string, consume consumeFunc) error {
+	// the reader reports to its own channel, so that an abandoned
+	// reader is able to finish although nobody waits for it.
+	readDone := make(chan error, 1)
+	done := make(chan error, 1)
+	doneIfPanic := func(done chan<- error, helper string) {
 		if r := recover(); r != nil {
 			done <- fmt.Errorf("%s on %s: %s", helper, name, r)
 		}
 	}
 
 	stopRead := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(stopRead) })
+	}
 	confirm := make(chan error)
-	rows := make(chan Row)
+	// only one of rows and batches is used depending on the reader.
+	var rows chan Row
+	var batches chan []Row
+	br, batched := p.reader.(BatchReader)
+	if batched {
+		batches = make(chan []Row)
+	} else {
+		rows = make(chan Row)
+	}
+	var stats <-chan ReadStats
+	// stopped is set by the reader goroutine before it reports
+	// being done, so it's safe to use once readDone is received.
+	var stopped bool
+	// abandoned is set by the consumer goroutine before it reports
+	// being done if the reader isn't waited for.
+	var abandoned bool
 
+	finished := make(chan struct{})
+	defer close(finished)
 	go func() {
-		defer doneIfPanic(fmt.Sprintf("Reader %T paniced", p.reader))
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-finished:
+		}
+	}()
+
+	go func() {
+		defer doneIfPanic(readDone, fmt.Sprintf("Reader %T paniced", p.reader))
 		defer func() {
-			close(rows)
+			if batched {
+				close(batches)
+			} else {
+				close(rows)
+			}
 			close(confirm)
 		}()
 
-		p.reader.Read(name, confirm, rows, stopRead)
-		done <- nil
+		if batched {
+			br.ReadBatches(name, confirm, batches, stopRead)
+		} else {
+			p.reader.Read(name, confirm, rows, stopRead)
+		}
+		select {
+		case <-stopRead:
+			stopped = true
+		default:
+		}
+		readDone <- nil
 	}()
 
 	go func() {
-		defer doneIfPanic("Template paniced")
+		defer doneIfPanic(done, consumer+" paniced")
 
-		if err, ok := <-confirm; !ok || err != nil {
-			done <- err
+		var timeout <-chan time.Time
+		if p.confirmTimeout > 0 {
+			timer := time.NewTimer(p.confirmTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case err, ok := <-confirm:
+			if !ok || err != nil {
+				stop()
+				// reader may try to send rows even after a failed
+				// confirm, it must be able to finish anyway.
+				drainRows(rows, batches)
+				done <- err
+				return
+			}
+		case <-timeout:
+			stop()
+			go func() {
+				<-confirm
+				// reader may wake up later, it must be able
+				// to finish although nobody waits for it.
+				drainRows(rows, batches)
+			}()
+			// the read is abandoned, so the reader isn't waited for.
+			abandoned = true
+			done <- fmt.Errorf("Reader of %s didn't confirm in %s: %w", name, p.confirmTimeout, ErrReadTimeout)
 			return
 		}
 
+		var counted <-chan Row
+		counted, stats = countRows(name, rows, batches, stopRead, stop, withTransforms(filter, p.transforms), p.maxErrorRows)
+		defer stop()
+		done <- consume(counted, stop)
+	}()
+
+	err := <-done
+	if !abandoned {
+		err = joinErrors(<-readDone, err)
+	}
+	if stats != nil {
+		s := <-stats
+		s.Stopped = stopped
+		p.report(s)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// flushLines defines how many lines of output, i.e. rows
+// mostly, are written between flushes of flushWriter.
+const flushLines = 50
+
+// flushWriter flushes the underlying writer on newline boundaries
+// once the specified number of lines are written.
+type flushWriter struct {
+	w     io.Writer
+	f     http.Flusher
+	every int
+	lines int
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.lines += bytes.Count(p[:n], []byte{'\n'})
+	if fw.lines >= fw.every {
+		fw.f.Flush()
+		fw.lines = 0
+	}
+	return n, err
+}
+
+// report logs the specified stats if read was stopped
+// and passes them to the stats function if it is set.
+func (p *Producer) report(s ReadStats) {
+	if s.Stopped {
+		log.Printf("[SPREADSHEET] Read of %s stopped after %d rows", s.Name, s.Rows)
+	}
+	if p.statsFunc != nil {
+		p.statsFunc(s)
+	}
+}
+
+// rowFilter decides whether the row is output and may replace it.
+type rowFilter func(Row) (Row, bool)
+
+// countRows relays the specified rows accepted by the filter to the
+// returned channel counting them on the way. Rows come either one by one
+// through rows or in batches through batches, the other channel is nil.
+// Once stop is closed, the relay is finished and the rest of rows is
+// drained to allow reader to finish gracefully. Stats are sent when the
+// channel of rows is closed. It's up to the caller to tell whether the
+// read is stopped.
+// If maxErrors is positive, the relay is finished with a "too many
+// errors" row and the read is aborted once there are more error rows.
+func countRows(name string, rows <-chan Row, batches <-chan []Row, stop <-chan struct{}, abort func(), filter rowFilter, maxErrors int) (<-chan Row, <-chan ReadStats) {
+	counted := make(chan Row)
+	stats := make(chan ReadStats, 1)
+	go func() {
+		s := ReadStats{Name: name}
+		errorRows := 0
 		defer func() {
-			close(stopRead)
-			for _ = range rows {
-				// allow reader to finish gracefully
-			}
+			// allow reader to finish gracefully
+			drainRows(rows, batches)
+			stats <- s
 		}()
-		data := templateData{
-			Title: name,
-			Rows:  rows,
+		defer close(counted)
+
+		// relay passes the row on and reports whether to go on.
+		relay := func(row Row) bool {
+			if filter != nil {
+				var ok bool
+				if row, ok = filter(row); !ok {
+					return true
+				}
+			}
+			tooMany := false
+			if row.ErrorMessage != nil {
+				errorRows++
+				if maxErrors > 0 && errorRows > maxErrors {
+					msg := fmt.Sprintf("Too many errors, read is aborted after %d error rows", maxErrors)
+					row = Row{ErrorMessage: &msg}
+					tooMany = true
+				}
+			}
+			select {
+			case counted <- row:
+				s.Rows++
+			case <-stop:
+				return false
+			}
+			if tooMany {
+				abort()
+				return false
+			}
+			return true
+		}
+
+		for {
+			select {
+			case row, ok := <-rows:
+				if !ok || !relay(row) {
+					return
+				}
+			case batch, ok := <-batches:
+				if !ok {
+					return
+				}
+				for _, row := range batch {
+					if !relay(row) {
+						return
+					}
+				}
+			case <-stop:
+				return
+			}
 		}
-		done <- p.htmlTemplate.Execute(w, data)
 	}()
+	return counted, stats
+}
 
-	return waitForDone(done)
+// dropExtra relays rows without their extra fields.
+func dropExtra(rows <-chan Row) <-chan Row {
+	out := make(chan Row)
+	go func() {
+		defer close(out)
+		for row := range rows {
+			row.Extra = nil
+			out <- row
+		}
+	}()
+	return out
 }
 
-// waitForDone drains a given done channel according to its capacity.
-// If there is more than one error (which is rare), compound error
-// message will be returned.
-func waitForDone(done <-chan error) error {
-	var result error
-	for i := 0; i < cap(done); i++ {
-		if err := <-done; err != nil {
-			if result == nil {
-				result = err
-			} else {
-				result = fmt.Errorf("%s; [add] %s", result, err)
+// paginate groups the specified rows into pages of the specified size.
+// The first row, if not nil, goes before the rows.
+// The returned channel is closed after rows channel is closed.
+func paginate(first *Row, rows <-chan Row, size int) <-chan page {
+	pages := make(chan page)
+	go func() {
+		defer close(pages)
+		pg := page{}
+		add := func(row Row) {
+			pg.Rows = append(pg.Rows, row)
+			if len(pg.Rows) == size {
+				pages <- pg
+				pg = page{Index: pg.Index + 1}
 			}
 		}
+		if first != nil {
+			add(*first)
+		}
+		for row := range rows {
+			add(row)
+		}
+		if len(pg.Rows) != 0 {
+			pages <- pg
+		}
+	}()
+	return pages
+}
+
+// joinErrors joins the specified errors skipping nil ones. If there is
+// more than one error (which is rare), they are joined so that each of
+// them can still be inspected with errors.Is.
+func joinErrors(all ...error) error {
+	var errs []error
+	for _, err := range all {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
 	}
-	return result
+	return errors.Join(errs...)
 }
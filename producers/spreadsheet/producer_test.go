@@ -2,8 +2,15 @@ package spreadsheet
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -96,6 +103,32 @@ func TestHtml_SuccessfulRead_CorrectHtml(t *testing.T) {
 	assert.Contains(t, s, `<td>name2</td><td>addr2</td><td>postcode2</td><td>phone2</td><td align="right">2.31</td><td align="right">1992-06-05</td>`)
 }
 
+func TestHtml_EmailAndCompany_CorrectHtml(t *testing.T) {
+	r := testReader{
+		rows: []Row{
+			{
+				Name:        "name1",
+				Address:     "addr1",
+				Postcode:    "postcode1",
+				Phone:       "phone1",
+				CreditLimit: "1.45",
+				Birthday:    "1991-01-02",
+				Email:       "name1@example.com",
+				Company:     "company1",
+			},
+		},
+	}
+	var buf bytes.Buffer
+
+	p := NewProducer(&r)
+	err := p.HTML(&buf, "success")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<td>Birthday</td><td>Email</td><td>Company</td>`)
+	assert.Contains(t, s, `<td align="right">1991-01-02</td><td>name1@example.com</td><td>company1</td>`)
+}
+
 func TestHtml_ErrorInSomeRows_CorrectHtml(t *testing.T) {
 	errMsg := "oops sorry"
 	r := testReader{
@@ -126,19 +159,16 @@ func TestHtml_ErrorInSomeRows_CorrectHtml(t *testing.T) {
 
 	s := buf.String()
 	assert.Contains(t, s, `<title>success</title>`)
-	assert.Contains(t, s, `<td colspan="6">oops sorry</td>`)
+	assert.Contains(t, s, `<td colspan="8">oops sorry</td>`)
 	assert.Contains(t, s, `<td>name2</td><td>addr2</td><td>postcode2</td><td>phone2</td><td align="right">2.31</td><td align="right">1992-06-05</td>`)
 }
 
-func TestWaitForDone_DoneWithoutErrors_NoError(t *testing.T) {
-	done := make(chan error, 2)
-	done <- nil
-	done <- nil
-	err := waitForDone(done)
+func TestJoinErrors_NilErrors_NoError(t *testing.T) {
+	err := joinErrors(nil, nil)
 	assert.NoError(t, err)
 }
 
-func TestWaitForDone_DoneWithError_ErrorReturned(t *testing.T) {
+func TestJoinErrors_SomeErrors_ErrorReturned(t *testing.T) {
 	testCases := []struct {
 		errs []error
 		want string
@@ -151,16 +181,829 @@ func TestWaitForDone_DoneWithError_ErrorReturned(t *testing.T) {
 			want: "err1",
 		}, {
 			errs: []error{errors.New("err1"), errors.New("err2"), errors.New("err3")},
-			want: "err1; [add] err2; [add] err3",
+			want: "err1\nerr2\nerr3",
 		},
 	}
 
 	for _, testCase := range testCases {
-		done := make(chan error, len(testCase.errs))
-		for _, err := range testCase.errs {
-			done <- err
-		}
-		err := waitForDone(done)
+		err := joinErrors(testCase.errs...)
 		assert.EqualError(t, err, testCase.want)
 	}
 }
+
+func TestHtml_PrintProducer_PageBreakEveryPageSizeRows(t *testing.T) {
+	r := testReader{rows: make([]Row, 5)}
+	for i := range r.rows {
+		r.rows[i].Name = fmt.Sprintf("name%d", i)
+	}
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&r, 2)
+	err := p.HTML(&buf, "print")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	pageBreak := `<div style="page-break-after: always"></div>`
+	header := `<tr style="font-weight: Bold">`
+	assert.Equal(t, 2, strings.Count(s, pageBreak))
+	assert.Equal(t, 3, strings.Count(s, header))
+
+	pages := strings.Split(s, pageBreak)
+	if assert.Len(t, pages, 3) {
+		assert.Contains(t, pages[0], "<td>name0</td>")
+		assert.Contains(t, pages[0], "<td>name1</td>")
+		assert.Contains(t, pages[1], header)
+		assert.Contains(t, pages[1], "<td>name2</td>")
+		assert.Contains(t, pages[1], "<td>name3</td>")
+		assert.Contains(t, pages[2], header)
+		assert.Contains(t, pages[2], "<td>name4</td>")
+	}
+}
+
+func TestHtml_PrintProducerEmptyRead_NoPageBreak(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&testReader{}, 2)
+	err := p.HTML(&buf, "print")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "page-break-after")
+}
+
+func TestHtml_EmptyRead_NoRecordsPlaceholder(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{})
+	err := p.HTML(&buf, "empty")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<title>empty</title>")
+	assert.Contains(t, buf.String(), "No records found")
+	assert.NotContains(t, buf.String(), "<table")
+}
+
+func TestHtml_PrintProducerEmptyRead_NoRecordsPlaceholder(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&testReader{}, 2)
+	err := p.HTML(&buf, "print")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "No records found")
+	assert.NotContains(t, buf.String(), "<table")
+}
+
+func TestHtml_SomeRows_NoPlaceholder(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "name1"}}})
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "No records found")
+	assert.Contains(t, buf.String(), "<td>name1</td>")
+}
+
+func TestNewPrintProducer_InvalidPageSize_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewPrintProducer(&testReader{}, 0) })
+}
+
+// failingWriter fails as soon as it's asked to write the specified content.
+type failingWriter struct {
+	failOn string
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.failOn) {
+		return 0, errors.New("connection reset")
+	}
+	return len(p), nil
+}
+
+func TestHtml_ReadCompleted_StatsReported(t *testing.T) {
+	r := testReader{rows: make([]Row, 5)}
+	var reported []ReadStats
+
+	p := NewProducer(&r)
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	err := p.HTML(&bytes.Buffer{}, "name1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []ReadStats{{Name: "name1", Rows: 5}}, reported)
+}
+
+func TestHtml_ReadStoppedAfterKRows_StatsReportedWithK(t *testing.T) {
+	r := testReader{rows: make([]Row, 10)}
+	for i := range r.rows {
+		r.rows[i].Name = fmt.Sprintf("name%d", i)
+	}
+	var reported []ReadStats
+	logBuf := bytes.Buffer{}
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	p := NewProducer(&r)
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	// 4 rows are passed to the output and the writer fails on the last one.
+	err := p.HTML(failingWriter{failOn: "name3"}, "name1")
+	assert.Error(t, err)
+
+	assert.Equal(t, []ReadStats{{Name: "name1", Rows: 4, Stopped: true}}, reported)
+	assert.Contains(t, logBuf.String(), "[SPREADSHEET] Read of name1 stopped after 4 rows")
+}
+
+func TestHtml_PrintProducerReadStopped_StatsReported(t *testing.T) {
+	r := testReader{rows: make([]Row, 10)}
+	for i := range r.rows {
+		r.rows[i].Name = fmt.Sprintf("name%d", i)
+	}
+	var reported []ReadStats
+
+	p := NewPrintProducer(&r, 3)
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	err := p.HTML(failingWriter{failOn: "name4"}, "name1")
+	assert.Error(t, err)
+
+	// paginate keeps a page of 3 rows ahead of the output.
+	if assert.Len(t, reported, 1) {
+		assert.True(t, reported[0].Stopped)
+		assert.True(t, reported[0].Rows >= 6, "rows: %d", reported[0].Rows)
+	}
+}
+
+func TestHtml_ReadError_StatsNotReported(t *testing.T) {
+	r := testReader{err: errors.New("must read, but won't")}
+	reported := false
+
+	p := NewProducer(&r)
+	p.ReportStats(func(s ReadStats) { reported = true })
+	p.HTML(&bytes.Buffer{}, "name")
+
+	assert.False(t, reported)
+}
+
+// confirmThenRowReader reports an error on confirm but still sends a row.
+type confirmThenRowReader struct{}
+
+func (r confirmThenRowReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	confirm <- errors.New("confirm failed")
+	rows <- Row{Name: "unexpected"}
+}
+
+func TestHtml_RowSentAfterConfirmError_NoDeadlock(t *testing.T) {
+	p := NewProducer(confirmThenRowReader{})
+	b := bytes.Buffer{}
+
+	result := make(chan error)
+	go func() {
+		result <- p.HTML(&b, "name")
+	}()
+
+	select {
+	case err := <-result:
+		assert.EqualError(t, err, "confirm failed")
+		assert.Len(t, b.Bytes(), 0)
+	case <-time.After(time.Second):
+		t.Fatal("HTML is deadlocked")
+	}
+}
+
+func TestJoinErrors_SeveralErrors_EachErrorIsInspectable(t *testing.T) {
+	tmplErr := errors.New("template failed")
+
+	err := joinErrors(fmt.Errorf("reader failed: %w", os.ErrNotExist), tmplErr)
+
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.True(t, errors.Is(err, tmplErr))
+}
+
+func TestInvalidRow_LineAndRaw_MessageWithPreview(t *testing.T) {
+	testCases := []struct {
+		line int
+		raw  string
+		want string
+	}{
+		{line: 42, raw: "", want: "Invalid row 42"},
+		{line: 42, raw: "Leon,Mike\n", want: `Invalid row 42: "Leon,Mike"`},
+		{line: 7, raw: "\"Leon, Mike\",Dorpsplein 5A\n", want: `Invalid row 7: "\"Leon, Mike\",Dorpspl..."`},
+		{line: 1, raw: "Yørkstraße 22 Yørkstraße 22", want: `Invalid row 1: "Yørkstraße 22 Yørkst..."`},
+	}
+
+	for _, tc := range testCases {
+		row := InvalidRow(tc.line, tc.raw)
+		if assert.NotNil(t, row.ErrorMessage) {
+			assert.Equal(t, tc.want, *row.ErrorMessage)
+		}
+	}
+}
+
+func TestCreditLimitValid_VariousInputs_CorrectResult(t *testing.T) {
+	testCases := []struct {
+		creditLimit string
+		want        bool
+	}{
+		{creditLimit: "50000", want: true},
+		{creditLimit: "4598.1", want: true},
+		{creditLimit: "-10", want: true},
+		{creditLimit: "", want: true},
+		{creditLimit: "N/A", want: false},
+		{creditLimit: "NaN", want: false},
+		{creditLimit: "1,000", want: false},
+	}
+
+	for _, tc := range testCases {
+		row := Row{CreditLimit: tc.creditLimit}
+		assert.Equal(t, tc.want, row.CreditLimitValid(), "credit limit: %q", tc.creditLimit)
+	}
+}
+
+func TestHtml_ValidateCreditLimitNonNumeric_HighlightedCell(t *testing.T) {
+	r := testReader{rows: []Row{{CreditLimit: "N/A"}, {CreditLimit: "1.45"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(&r)
+	p.ValidateCreditLimit(true)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<td align="right" style="color: red">N/A</td>`)
+	assert.Contains(t, s, `<td align="right">1.45</td>`)
+}
+
+func TestHtml_NoValidateCreditLimitNonNumeric_RegularCell(t *testing.T) {
+	r := testReader{rows: []Row{{CreditLimit: "N/A"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(&r)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `<td align="right">N/A</td>`)
+}
+
+// slowReader sends rows with a delay until it's stopped.
+type slowReader struct{}
+
+func (r slowReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	confirm <- nil
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Millisecond):
+			rows <- Row{Name: "slow"}
+		}
+	}
+}
+
+func TestHtmlContext_Timeout_ReadStoppedAndErrorReturned(t *testing.T) {
+	var reported []ReadStats
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	p := NewProducer(slowReader{})
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	err := p.HTMLContext(ctx, &bytes.Buffer{}, "name")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	if assert.Len(t, reported, 1) {
+		assert.True(t, reported[0].Stopped)
+	}
+}
+
+// flushCountingWriter counts flushes along with rows written before them.
+type flushCountingWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.flushes++
+}
+
+func TestHtml_FlusherWriterManyRows_FlushedPeriodically(t *testing.T) {
+	r := testReader{rows: make([]Row, 4*flushLines)}
+	w := flushCountingWriter{}
+
+	p := NewProducer(&r)
+	err := p.HTML(&w, "name")
+	assert.NoError(t, err)
+
+	assert.True(t, w.flushes >= 4, "flushes: %d", w.flushes)
+	assert.Equal(t, 4*flushLines, strings.Count(w.String(), "<tr><td>"))
+}
+
+func TestHtml_FlusherWriterFewRows_NotFlushed(t *testing.T) {
+	r := testReader{rows: make([]Row, 2)}
+	w := flushCountingWriter{}
+
+	p := NewProducer(&r)
+	err := p.HTML(&w, "name")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, w.flushes)
+}
+
+func TestHtml_HighlightErrorRows_ClassAndLabelOnErrorRowsOnly(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie"}, {ErrorMessage: &errMsg}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	p.HighlightErrorRows("error-row")
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<tr class="error-row"><td colspan="8">Error: oops sorry</td></tr>`)
+	assert.Contains(t, s, `<tr><td>Stewart, Jamie</td>`)
+	assert.Equal(t, 1, strings.Count(s, `class="error-row"`))
+	assert.Contains(t, s, `<style>.error-row { background-color: #fcc; }</style>`)
+}
+
+func TestHtml_NoHighlight_ErrorRowsUnchanged(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{{ErrorMessage: &errMsg}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<tr><td colspan="8">oops sorry</td></tr>`)
+	assert.NotContains(t, s, "Error:")
+	assert.NotContains(t, s, "<style>")
+}
+
+func TestRows_SuccessfulRead_RowsAndNilErrorProvided(t *testing.T) {
+	r := testReader{rows: []Row{{Name: "name1"}, {Name: "name2"}}}
+	p := NewProducer(&r)
+
+	rows, errc := p.Rows("name")
+	var received []Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, r.rows, received)
+	assert.NoError(t, <-errc)
+	assert.Equal(t, "name", r.readName)
+}
+
+func TestRows_ReadError_NoRowsAndErrorProvided(t *testing.T) {
+	r := testReader{err: errors.New("must read, but won't")}
+	p := NewProducer(&r)
+
+	rows, errc := p.Rows("name")
+	var received []Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Empty(t, received)
+	assert.EqualError(t, <-errc, "must read, but won't")
+}
+
+func TestRows_ReadPanic_ErrorProvided(t *testing.T) {
+	r := testReader{panic: "something went wrong"}
+	p := NewProducer(&r)
+
+	rows, errc := p.Rows("name1")
+	for _ = range rows {
+	}
+
+	assert.EqualError(t, <-errc, "Reader *spreadsheet.testReader paniced on name1: something went wrong")
+}
+
+func TestRowsContext_Cancelled_ReadStoppedAndErrorProvided(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewProducer(slowReader{})
+
+	rows, errc := p.RowsContext(ctx, "name")
+	assert.Equal(t, "slow", (<-rows).Name)
+	cancel()
+	for _ = range rows {
+	}
+
+	assert.Equal(t, context.Canceled, <-errc)
+}
+
+func TestRows_FullRead_StatsNotStopped(t *testing.T) {
+	var reported []ReadStats
+	r := testReader{rows: []Row{{Name: "name1"}, {Name: "name2"}}}
+	p := NewProducer(&r)
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+
+	rows, errc := p.Rows("name")
+	for _ = range rows {
+	}
+
+	assert.NoError(t, <-errc)
+	assert.Equal(t, []ReadStats{{Name: "name", Rows: 2}}, reported)
+}
+
+func TestRowsContext_Cancelled_StatsStopped(t *testing.T) {
+	var reported []ReadStats
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewProducer(slowReader{})
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+
+	rows, errc := p.RowsContext(ctx, "name")
+	<-rows
+	cancel()
+	for _ = range rows {
+	}
+
+	assert.Equal(t, context.Canceled, <-errc)
+	if assert.Len(t, reported, 1) {
+		assert.True(t, reported[0].Stopped)
+		assert.True(t, reported[0].Rows >= 1)
+	}
+}
+
+func TestHtml_PrintProducerFullRead_StatsNotStopped(t *testing.T) {
+	var reported []ReadStats
+	r := testReader{rows: make([]Row, 5)}
+
+	p := NewPrintProducer(&r, 2)
+	p.ReportStats(func(s ReadStats) { reported = append(reported, s) })
+	err := p.HTML(&bytes.Buffer{}, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ReadStats{{Name: "name", Rows: 5}}, reported)
+}
+
+func TestRowAge_KnownBirthdays_FullYearsReturned(t *testing.T) {
+	now := time.Date(2017, 10, 8, 12, 0, 0, 0, time.UTC)
+	cases := map[string]string{
+		"1982-02-01": "35",
+		"1967-10-08": "50",
+		"1967-10-09": "49",
+		"2017-10-09": "",
+		"01/02/1982": "",
+		"":           "",
+	}
+	for birthday, age := range cases {
+		assert.Equal(t, age, Row{Birthday: birthday}.Age(now), birthday)
+	}
+}
+
+func TestHtmlWithAge_FixedClock_AgeColumnRendered(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", Birthday: "unknown"},
+		{ErrorMessage: &errMsg},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	p.SetClock(func() time.Time { return time.Date(2017, 10, 8, 0, 0, 0, 0, time.UTC) })
+	err := p.HTMLWithAge(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Company</td><td>Age</td></tr>")
+	assert.Contains(t, s, `<td align="right">1982-02-01</td><td></td><td></td><td align="right">35</td></tr>`)
+	assert.Contains(t, s, `<td align="right">unknown</td><td></td><td></td><td align="right"></td></tr>`)
+	assert.Contains(t, s, `<td colspan="9">oops sorry</td>`)
+}
+
+func TestHtml_WithoutAge_NoAgeColumn(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie", Birthday: "1982-02-01"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "Age")
+}
+
+func TestHtml_ShowExtraColumns_ExtraColumnsRendered(t *testing.T) {
+	errMsg := "oops sorry"
+	extra := func(region, segment string) []Field {
+		return []Field{{Name: "Region", Value: region}, {Name: "Segment", Value: segment}}
+	}
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Extra: extra("North", "Retail")},
+		{ErrorMessage: &errMsg, Extra: extra("", "")},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	p.ShowExtraColumns(true)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Company</td><td>Region</td><td>Segment</td></tr>")
+	assert.Contains(t, s, "<td></td><td>North</td><td>Retail</td></tr>")
+	assert.Contains(t, s, `<td colspan="10">oops sorry</td>`)
+}
+
+func TestHtml_NoShowExtraColumns_ExtraColumnsIgnored(t *testing.T) {
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Extra: []Field{{Name: "Region", Value: "North"}}},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "Region")
+	assert.NotContains(t, buf.String(), "North")
+}
+
+func wideRows(extra int) []Row {
+	errMsg := "oops sorry"
+	fields := func(value string) []Field {
+		var f []Field
+		for i := 0; i < extra; i++ {
+			f = append(f, Field{Name: fmt.Sprintf("Extra%d", i), Value: value})
+		}
+		return f
+	}
+	return []Row{
+		{Name: "Stewart, Jamie", Extra: fields("wide")},
+		{ErrorMessage: &errMsg, Extra: fields("")},
+		{Name: "Leon, Mike", Extra: fields("wide")},
+	}
+}
+
+func TestHtml_ExtraColumnsOverDefaultLimit_OnlyKnownColumnsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: wideRows(1000)})
+	p.ShowExtraColumns(true)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<p>1000 extra columns are not shown since there are too many columns</p>")
+	assert.Contains(t, s, "<td>Company</td></tr>")
+	assert.Contains(t, s, "<td>Leon, Mike</td>")
+	assert.Contains(t, s, `<td colspan="8">oops sorry</td>`)
+	assert.NotContains(t, s, "Extra0")
+	assert.NotContains(t, s, "wide")
+}
+
+func TestHtml_ExtraColumnsWithinLimit_ExtraColumnsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: wideRows(2)})
+	p.ShowExtraColumns(true)
+	p.LimitColumns(len(Columns) + 2)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<td>Extra1</td></tr>")
+	assert.NotContains(t, buf.String(), "not shown")
+}
+
+func TestHtml_PrintProducerExtraColumnsOverLimit_OnlyKnownColumnsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&testReader{rows: wideRows(3)}, 2)
+	p.ShowExtraColumns(true)
+	p.LimitColumns(len(Columns) + 2)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<p>3 extra columns are not shown since there are too many columns</p>")
+	assert.NotContains(t, buf.String(), "wide")
+}
+
+func TestHtml_PrintProducerExtraColumnsOverDefaultLimit_OnlyKnownColumnsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&testReader{rows: wideRows(100)}, 2)
+	p.ShowExtraColumns(true)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<p>100 extra columns are not shown since there are too many columns</p>")
+	assert.NotContains(t, buf.String(), "wide")
+}
+
+func TestHtml_ExtraColumnsOverLimitWriteFails_ReadFinished(t *testing.T) {
+	p := NewProducer(&testReader{rows: wideRows(100)})
+	p.ShowExtraColumns(true)
+	err := p.HTML(failingWriter{failOn: "Leon"}, "name")
+	assert.Error(t, err)
+}
+
+func TestRows_LimitErrorRowsExceeded_TooManyErrorsRowLast(t *testing.T) {
+	errMsg := "oops sorry"
+	r := testReader{rows: []Row{{Name: "name1"}, {ErrorMessage: &errMsg}, {Name: "name2"}, {ErrorMessage: &errMsg}, {ErrorMessage: &errMsg}, {Name: "name3"}}}
+	p := NewProducer(&r)
+	p.LimitErrorRows(2)
+
+	rows, errc := p.Rows("name")
+	var received []Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.NoError(t, <-errc)
+	if assert.Len(t, received, 5) {
+		assert.Equal(t, r.rows[:4], received[:4])
+		if assert.NotNil(t, received[4].ErrorMessage) {
+			assert.Equal(t, "Too many errors, read is aborted after 2 error rows", *received[4].ErrorMessage)
+		}
+	}
+}
+
+func TestRows_NoLimitErrorRows_AllErrorRowsProvided(t *testing.T) {
+	errMsg := "oops sorry"
+	r := testReader{rows: make([]Row, 100)}
+	for i := range r.rows {
+		r.rows[i].ErrorMessage = &errMsg
+	}
+	p := NewProducer(&r)
+
+	rows, errc := p.Rows("name")
+	var received []Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.NoError(t, <-errc)
+	assert.Equal(t, r.rows, received)
+}
+
+func TestHtml_SetTitleFormat_FormattedTitleAndHeading(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "name1"}}})
+	p.SetTitleFormat("Report: %s")
+	err := p.HTML(&buf, "customers")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<title>Report: customers</title>")
+	assert.Contains(t, buf.String(), "<h1>Report: customers</h1>")
+}
+
+func TestHtml_PrintProducerSetTitle_TitleAndHeadingFromFunction(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewPrintProducer(&testReader{rows: []Row{{Name: "name1"}}}, 2)
+	p.SetTitle(strings.ToUpper)
+	err := p.HTML(&buf, "customers")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<title>CUSTOMERS</title>")
+	assert.Contains(t, buf.String(), "<h1>CUSTOMERS</h1>")
+}
+
+func TestHtml_NoTitle_NameAsTitleWithoutHeading(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "name1"}}})
+	err := p.HTML(&buf, "customers")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<title>customers</title>")
+	assert.NotContains(t, buf.String(), "<h1>")
+}
+
+func TestHtml_HTMLInteractive_ScriptAndSortableHeader(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "name1"}, {Name: "name2"}}})
+	p.HTMLInteractive(true)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, `<tr style="font-weight: Bold" class="sortable"><td>Name</td>`)
+	assert.Contains(t, s, "<script>")
+	assert.Contains(t, s, `document.querySelectorAll("tr.sortable")`)
+	assert.Contains(t, s, `cell.addEventListener("click"`)
+	assert.True(t, strings.Index(s, "</table>") < strings.Index(s, "<script>"))
+}
+
+func TestHtml_NoHTMLInteractive_PlainOutput(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "name1"}}})
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "sortable")
+	assert.NotContains(t, buf.String(), "<script>")
+}
+
+type layoutReader struct {
+	testReader
+}
+
+func (r *layoutReader) Layout(name string) (interface{}, error) {
+	return map[string]int{"Name": 0}, nil
+}
+
+func TestLayout_LayoutReader_ReaderLayoutReturned(t *testing.T) {
+	p := NewProducer(&layoutReader{})
+	lt, err := p.Layout("name")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"Name": 0}, lt)
+}
+
+func TestLayout_PlainReader_ErrUnsupportedReturned(t *testing.T) {
+	p := NewProducer(&testReader{})
+	_, err := p.Layout("name")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+type probeReader struct {
+	testReader
+}
+
+func (r *probeReader) Probe() error {
+	return r.err
+}
+
+func TestProbe_Prober_ReaderErrorReturned(t *testing.T) {
+	p := NewProducer(&probeReader{testReader{err: errors.New("storage is gone")}})
+	assert.EqualError(t, p.Probe(), "storage is gone")
+}
+
+func TestProbe_PlainReader_ErrUnsupportedReturned(t *testing.T) {
+	p := NewProducer(&testReader{})
+	assert.True(t, errors.Is(p.Probe(), errors.ErrUnsupported))
+}
+
+// hangingReader never confirms read until released.
+type hangingReader struct {
+	release chan struct{}
+}
+
+func (r hangingReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	<-r.release
+	confirm <- nil
+	rows <- Row{Name: "late"}
+}
+
+func TestHtml_ReaderNeverConfirms_TimeoutErrorReturned(t *testing.T) {
+	r := hangingReader{release: make(chan struct{})}
+	defer close(r.release)
+	var buf bytes.Buffer
+
+	p := NewProducerWithTimeout(r, 20*time.Millisecond)
+	start := time.Now()
+	err := p.HTML(&buf, "name")
+
+	assert.True(t, errors.Is(err, ErrReadTimeout), "%v", err)
+	assert.EqualError(t, err, "Reader of name didn't confirm in 20ms: Read timed out")
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Empty(t, buf.String())
+}
+
+// lateReader confirms read and sends a row once released ignoring stop.
+// It closes finished once Read returns.
+type lateReader struct {
+	release  chan struct{}
+	finished chan struct{}
+}
+
+func (r lateReader) Read(name string, confirm chan<- error, rows chan<- Row, stop <-chan struct{}) {
+	defer close(r.finished)
+	<-r.release
+	confirm <- nil
+	rows <- Row{Name: "late"}
+}
+
+func TestHtml_ReaderConfirmsAfterTimeout_ReaderGoroutineExits(t *testing.T) {
+	r := lateReader{release: make(chan struct{}), finished: make(chan struct{})}
+	before := runtime.NumGoroutine()
+
+	p := NewProducerWithTimeout(r, 10*time.Millisecond)
+	err := p.HTML(&bytes.Buffer{}, "name")
+	assert.True(t, errors.Is(err, ErrReadTimeout), "%v", err)
+
+	close(r.release)
+	<-r.finished
+	// goroutines of the abandoned read exit on their own shortly.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before, "%d goroutines instead of %d", runtime.NumGoroutine(), before)
+}
+
+func TestHtml_ReaderConfirmsInTime_RowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducerWithTimeout(&testReader{rows: []Row{{Name: "Stewart, Jamie"}}}, time.Second)
+	err := p.HTML(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<td>Stewart, Jamie</td>")
+}
+
+func TestNewProducerWithTimeout_InvalidTimeout_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewProducerWithTimeout(&testReader{}, 0) })
+}
@@ -0,0 +1,51 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RawReader is an optional interface implemented by Readers of files that
+// are able to provide the raw content of a spreadsheet.
+type RawReader interface {
+	// Raw opens the file of the spreadsheet with a given name.
+	Raw(name string) (io.ReadCloser, error)
+}
+
+// Raw writes the specified number of first lines of the file behind the
+// spreadsheet with the specified name as is, without parsing, e.g. to find
+// out why columns aren't detected. Errors of loading the file are returned
+// as is, so a missing file is reported by an error wrapping os.ErrNotExist.
+// If the reader isn't a RawReader, an error wrapping errors.ErrUnsupported
+// is returned.
+func (p *Producer) Raw(w io.Writer, name string, lines int) error {
+	if lines <= 0 {
+		return fmt.Errorf("Invalid number of lines %d", lines)
+	}
+	rr, ok := p.reader.(RawReader)
+	if !ok {
+		return fmt.Errorf("Reader %T doesn't provide raw content: %w", p.reader, errors.ErrUnsupported)
+	}
+	f, err := rr.Raw(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for i := 0; i < lines; i++ {
+		line, err := r.ReadBytes('\n')
+		if _, werr := w.Write(line); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rawReader provides raw content of the only file named "name".
+type rawReader struct {
+	testReader
+	content string
+}
+
+func (r *rawReader) Raw(name string) (io.ReadCloser, error) {
+	if name != "name" {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(r.content)), nil
+}
+
+func TestRaw_FirstLines_WrittenAsIs(t *testing.T) {
+	r := &rawReader{content: "Name,Address\r\n\"Stewart, Jamie\",Voorstraat 47\r\n\"Leon, Mike\",Dorpsplein 5A\r\nKling\r\n"}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.Raw(&buf, "name", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Name,Address\r\n\"Stewart, Jamie\",Voorstraat 47\r\n\"Leon, Mike\",Dorpsplein 5A\r\n", buf.String())
+}
+
+func TestRaw_FewerLinesThanRequested_WholeContentWritten(t *testing.T) {
+	r := &rawReader{content: "Name\nKling"}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.Raw(&buf, "name", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Name\nKling", buf.String())
+}
+
+func TestRaw_MissingFile_ErrNotExistReturned(t *testing.T) {
+	p := NewProducer(&rawReader{})
+	err := p.Raw(&bytes.Buffer{}, "other", 3)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestRaw_PlainReader_ErrUnsupportedReturned(t *testing.T) {
+	p := NewProducer(&testReader{})
+	err := p.Raw(&bytes.Buffer{}, "name", 3)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func TestRaw_InvalidLines_ErrorReturned(t *testing.T) {
+	p := NewProducer(&rawReader{})
+	err := p.Raw(&bytes.Buffer{}, "name", 0)
+	assert.EqualError(t, err, "Invalid number of lines 0")
+}
@@ -0,0 +1,50 @@
+package spreadsheet
+
+import (
+	"context"
+	"io"
+)
+
+// HTMLReversed generates output to display spreadsheet as a web page
+// with rows in reverse order, e.g. to show the latest entries of a log
+// first. Error rows keep their positions and only rows with data are
+// reversed around them. Since the last row is rendered first, all rows
+// are buffered before anything is written. So unlike HTML, the output
+// isn't streamed and memory usage grows with the size of the spreadsheet.
+func (p *Producer) HTMLReversed(w io.Writer, name string) error {
+	return p.run(context.Background(), name, nil, "Template", func(rows <-chan Row, stop func()) error {
+		var buffered []Row
+		for row := range rows {
+			buffered = append(buffered, row)
+		}
+		reverseRows(buffered)
+
+		replay := make(chan Row, len(buffered))
+		for _, row := range buffered {
+			replay <- row
+		}
+		close(replay)
+
+		return p.execute(w, name, nil, p.htmlTemplate, replay, stop)
+	})
+}
+
+// reverseRows reverses the order of rows with data in place.
+// Error rows stay where they are.
+func reverseRows(rows []Row) {
+	i, j := 0, len(rows)-1
+	for {
+		for i < j && rows[i].ErrorMessage != nil {
+			i++
+		}
+		for i < j && rows[j].ErrorMessage != nil {
+			j--
+		}
+		if i >= j {
+			return
+		}
+		rows[i], rows[j] = rows[j], rows[i]
+		i++
+		j--
+	}
+}
@@ -0,0 +1,44 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlReversed_OrderedRows_RenderedNewestFirst(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "2020-01-01"}, {Name: "2020-01-02"}, {Name: "2020-01-03"}}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLReversed(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	first := strings.Index(s, "<td>2020-01-03</td>")
+	second := strings.Index(s, "<td>2020-01-02</td>")
+	third := strings.Index(s, "<td>2020-01-01</td>")
+	assert.True(t, first >= 0 && first < second && second < third, "output: %s", s)
+}
+
+func TestReverseRows_ErrorRows_PositionsKept(t *testing.T) {
+	errMsg := "oops sorry"
+	rows := []Row{{Name: "a"}, {ErrorMessage: &errMsg}, {Name: "b"}, {Name: "c"}, {ErrorMessage: &errMsg}}
+
+	reverseRows(rows)
+
+	assert.Equal(t, []Row{{Name: "c"}, {ErrorMessage: &errMsg}, {Name: "b"}, {Name: "a"}, {ErrorMessage: &errMsg}}, rows)
+}
+
+func TestHtmlReversed_ReadError_ErrorReturned(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{err: errors.New("must read, but won't")})
+	err := p.HTMLReversed(&buf, "name")
+
+	assert.EqualError(t, err, "must read, but won't")
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,97 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Diff describes a value that changed in a round trip.
+type Diff struct {
+	// Row is the number of the row starting from 1.
+	Row int
+	// Column is the name of the column or of the extra field. It's
+	// "Error" if the message of an error row changed.
+	Column string
+	// Before is the value read originally.
+	Before string
+	// After is the value read back from CSV output.
+	After string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("Row %d, %s: %q became %q", d.Row, d.Column, d.Before, d.After)
+}
+
+// RoundTrip reads the spreadsheet with the specified name by the reader,
+// writes its rows as CSV does, reads them back by the reader that reread
+// makes of the CSV output and returns differences between the original rows
+// and those read back, e.g. to find out whether data survives an export
+// and an import by the CSV reader. Extra fields aren't output, so they are
+// reported as lost. No differences mean that the round trip is lossless.
+// An error is returned if either reader fails or the number of rows read
+// back differs.
+func RoundTrip(reader Reader, name string, reread func(output []byte) Reader) ([]Diff, error) {
+	original, err := readRows(reader, name)
+	if err != nil {
+		return nil, err
+	}
+	replay := make(chan Row, len(original))
+	for _, row := range original {
+		replay <- row
+	}
+	close(replay)
+	var buf bytes.Buffer
+	if err := NewProducer(reader).writeCSV(&buf, replay); err != nil {
+		return nil, err
+	}
+
+	back, err := readRows(reread(buf.Bytes()), name)
+	if err != nil {
+		return nil, err
+	}
+	if len(back) != len(original) {
+		return nil, fmt.Errorf("Read back %d rows of %s instead of %d", len(back), name, len(original))
+	}
+
+	var diffs []Diff
+	for i, row := range original {
+		diffs = append(diffs, compareRows(i+1, row, back[i])...)
+	}
+	return diffs, nil
+}
+
+// readRows reads all rows of the spreadsheet with the specified name.
+func readRows(reader Reader, name string) ([]Row, error) {
+	var result []Row
+	err := NewProducer(reader).run(context.Background(), name, nil, "RoundTrip", func(rows <-chan Row, stop func()) error {
+		for row := range rows {
+			result = append(result, row)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// compareRows returns differences between the original row
+// with the specified number and the row read back.
+func compareRows(num int, original, back Row) []Diff {
+	if original.ErrorMessage != nil {
+		msg := strings.TrimPrefix(back.Name, csvErrorPrefix)
+		if msg == back.Name || msg != *original.ErrorMessage {
+			return []Diff{{Row: num, Column: "Error", Before: *original.ErrorMessage, After: back.Name}}
+		}
+		return nil
+	}
+	var diffs []Diff
+	for _, col := range Columns {
+		if before, after := col.Get(original), col.Get(back); before != after {
+			diffs = append(diffs, Diff{Row: num, Column: col.Name, Before: before, After: after})
+		}
+	}
+	for _, f := range original.Extra {
+		diffs = append(diffs, Diff{Row: num, Column: f.Name, Before: f.Value})
+	}
+	return diffs
+}
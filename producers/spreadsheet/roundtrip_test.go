@@ -0,0 +1,79 @@
+package spreadsheet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rereadRows makes a reread function that ignores the output and
+// reads back the specified rows. The output is stored to output.
+func rereadRows(output *string, rows ...Row) func([]byte) Reader {
+	return func(b []byte) Reader {
+		*output = string(b)
+		return &testReader{rows: rows}
+	}
+}
+
+func TestRoundTrip_SameRowsReadBack_NoDiffs(t *testing.T) {
+	errMsg := "Invalid row 3"
+	rows := []Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat \"47\"", Postcode: "3123gg", Birthday: "1982-02-01"},
+		{ErrorMessage: &errMsg},
+	}
+	back := []Row{
+		rows[0],
+		{Name: "Error: Invalid row 3"},
+	}
+	var output string
+
+	diffs, err := RoundTrip(&testReader{rows: rows}, "name", rereadRows(&output, back...))
+
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+	assert.Equal(t, "Name,Address,Postcode,Phone,Credit Limit,Birthday,Email,Company\n"+
+		"\"Stewart, Jamie\",\"Voorstraat \"\"47\"\"\",3123gg,,,1982-02-01,,\n"+
+		"Error: Invalid row 3,,,,,,,\n", output)
+}
+
+func TestRoundTrip_FieldsChangedOnReadBack_DiffsReturned(t *testing.T) {
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Birthday: "01/02/1982"},
+		{Name: "Leon, Mike", Extra: []Field{{Name: "Hobby", Value: "chess"}}},
+	}}
+	var output string
+	expected := []Diff{
+		{Row: 1, Column: "Birthday", Before: "01/02/1982", After: "1982-02-01"},
+		{Row: 2, Column: "Hobby", Before: "chess"},
+	}
+
+	diffs, err := RoundTrip(r, "name", rereadRows(&output,
+		Row{Name: "Stewart, Jamie", Birthday: "1982-02-01"},
+		Row{Name: "Leon, Mike"}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, diffs)
+	assert.Equal(t, `Row 2, Hobby: "chess" became ""`, diffs[1].String())
+}
+
+func TestRoundTrip_RowsLostOnReadBack_ErrorReturned(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie"}, {Name: "Leon, Mike"}}}
+	var output string
+
+	_, err := RoundTrip(r, "name", rereadRows(&output, Row{Name: "Stewart, Jamie"}))
+
+	assert.EqualError(t, err, "Read back 1 rows of name instead of 2")
+}
+
+func TestRoundTrip_ReadError_ErrorReturned(t *testing.T) {
+	var output string
+	_, err := RoundTrip(&testReader{err: errors.New("must read, but won't")}, "name", rereadRows(&output))
+	assert.EqualError(t, err, "must read, but won't")
+}
+
+func TestRoundTrip_ReadBackError_ErrorReturned(t *testing.T) {
+	reread := func([]byte) Reader { return &testReader{err: errors.New("can't read back")} }
+	_, err := RoundTrip(&testReader{rows: []Row{{Name: "Stewart, Jamie"}}}, "name", reread)
+	assert.EqualError(t, err, "can't read back")
+}
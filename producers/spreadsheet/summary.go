@@ -0,0 +1,154 @@
+package spreadsheet
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postcodePrefixLen is the number of leading characters
+// of postcodes by which rows are grouped in summaries.
+const postcodePrefixLen = 2
+
+// Summary holds aggregate statistics of a spreadsheet.
+type Summary struct {
+	// Rows is the total number of rows including error rows.
+	Rows int
+	// ErrorRows is the number of error rows.
+	ErrorRows int
+	// Postcodes holds numbers of rows by postcode prefix in the order of
+	// prefixes. Rows without postcode are counted with empty prefix.
+	Postcodes []PostcodeCount
+	// CreditLimits is the number of numeric credit limits.
+	CreditLimits int
+	// CreditLimitSum is the sum of numeric credit limits.
+	CreditLimitSum float64
+}
+
+// PostcodeCount is the number of rows which postcodes have the prefix.
+type PostcodeCount struct {
+	Prefix string
+	Rows   int
+}
+
+// CreditLimitAvg returns the average of numeric credit limits
+// or zero if there are none.
+func (s Summary) CreditLimitAvg() float64 {
+	if s.CreditLimits == 0 {
+		return 0
+	}
+	return s.CreditLimitSum / float64(s.CreditLimits)
+}
+
+// summarize computes statistics of the specified rows.
+func summarize(rows <-chan Row) Summary {
+	s := Summary{}
+	postcodes := map[string]int{}
+	for row := range rows {
+		s.Rows++
+		if row.ErrorMessage != nil {
+			s.ErrorRows++
+			continue
+		}
+
+		prefix := strings.ToUpper(strings.Join(strings.Fields(row.Postcode), ""))
+		if runes := []rune(prefix); len(runes) > postcodePrefixLen {
+			prefix = string(runes[:postcodePrefixLen])
+		}
+		postcodes[prefix]++
+
+		if v := strings.TrimSpace(row.CreditLimit); v != "" && row.CreditLimitValid() {
+			if limit, err := strconv.ParseFloat(v, 64); err == nil {
+				s.CreditLimits++
+				s.CreditLimitSum += limit
+			}
+		}
+	}
+
+	for prefix, n := range postcodes {
+		s.Postcodes = append(s.Postcodes, PostcodeCount{Prefix: prefix, Rows: n})
+	}
+	sort.Slice(s.Postcodes, func(i, j int) bool {
+		return s.Postcodes[i].Prefix < s.Postcodes[j].Prefix
+	})
+	return s
+}
+
+const templateSummary = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>{{.Title}}</title>
+	</head>
+	<body>
+		<table style="font-family:Courier New, Courier, monospace; white-space:pre">
+			<tr><td>Rows</td><td align="right">{{.Summary.Rows}}</td></tr>
+			<tr><td>Error rows</td><td align="right">{{.Summary.ErrorRows}}</td></tr>
+			<tr><td>Credit limit sum</td><td align="right">{{printf "%.2f" .Summary.CreditLimitSum}}</td></tr>
+			<tr><td>Credit limit average</td><td align="right">{{printf "%.2f" .Summary.CreditLimitAvg}}</td></tr>
+		</table>
+		<table style="font-family:Courier New, Courier, monospace; white-space:pre">
+			<tr style="font-weight: Bold"><td>Postcode</td><td>Rows</td></tr>
+			{{range .Summary.Postcodes}}<tr><td>{{if .Prefix}}{{.Prefix}}{{else}}(none){{end}}</td><td align="right">{{.Rows}}</td></tr>
+			{{end}}
+		</table>
+	</body>
+</html>`
+
+var summaryTemplate = template.Must(template.New("summary").Parse(templateSummary))
+
+// summaryData provides data for summary HTML template.
+type summaryData struct {
+	Title   string
+	Summary Summary
+}
+
+// SummaryProducer provides aggregate statistics of spreadsheets
+// instead of their rows.
+type SummaryProducer struct {
+	p *Producer
+}
+
+// NewSummaryProducer creates and initializes a new instance of
+// SummaryProducer. Failures of the reader are handled in the same
+// way as by Producer.
+func NewSummaryProducer(reader Reader) *SummaryProducer {
+	return &SummaryProducer{p: NewProducer(reader)}
+}
+
+// HTML generates output to display statistics of spreadsheet as a web
+// page: total number of rows, number of error rows, number of rows by
+// postcode prefix and sum and average of credit limits. All rows are
+// read before anything is written.
+func (sp *SummaryProducer) HTML(w io.Writer, name string) error {
+	return sp.HTMLContext(context.Background(), w, name)
+}
+
+// HTMLContext acts as HTML but stops read once ctx is done.
+func (sp *SummaryProducer) HTMLContext(ctx context.Context, w io.Writer, name string) error {
+	return sp.p.run(ctx, name, nil, "Summary", func(rows <-chan Row, stop func()) error {
+		s := summarize(rows)
+		if ctx.Err() != nil {
+			// the summary of a partial read is misleading.
+			return nil
+		}
+		return summaryTemplate.Execute(w, summaryData{Title: name, Summary: s})
+	})
+}
+
+// Buffered reports that output is fine to buffer since it's small
+// and written only once all rows are read anyway.
+func (sp *SummaryProducer) Buffered() bool {
+	return true
+}
+
+// ModTime returns modification time of the spreadsheet with the specified
+// name if the reader is a ModTimer, otherwise false is returned.
+func (sp *SummaryProducer) ModTime(name string) (time.Time, bool) {
+	return sp.p.ModTime(name)
+}
@@ -0,0 +1,87 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func summaryFixture() []Row {
+	errMsg := "oops sorry"
+	return []Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg", CreditLimit: "50000"},
+		{Name: "Leon, Mike", Postcode: "3123 AA", CreditLimit: "201092"},
+		{Name: "Kling, Jeramie", Postcode: "4532 aa", CreditLimit: "unknown"},
+		{ErrorMessage: &errMsg},
+		{Name: "Gibson, Mal", CreditLimit: "4598.5"},
+	}
+}
+
+func TestSummarize_Fixture_TotalsComputed(t *testing.T) {
+	rows := make(chan Row, 5)
+	for _, row := range summaryFixture() {
+		rows <- row
+	}
+	close(rows)
+
+	s := summarize(rows)
+
+	assert.Equal(t, 5, s.Rows)
+	assert.Equal(t, 1, s.ErrorRows)
+	assert.Equal(t, []PostcodeCount{{Prefix: "", Rows: 1}, {Prefix: "31", Rows: 2}, {Prefix: "45", Rows: 1}}, s.Postcodes)
+	assert.Equal(t, 3, s.CreditLimits)
+	assert.Equal(t, 255690.5, s.CreditLimitSum)
+	assert.InDelta(t, 85230.1667, s.CreditLimitAvg(), 0.0001)
+}
+
+func TestSummaryHtml_Fixture_TotalsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewSummaryProducer(&testReader{rows: summaryFixture()})
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<title>name</title>")
+	assert.Contains(t, s, `<tr><td>Rows</td><td align="right">5</td></tr>`)
+	assert.Contains(t, s, `<tr><td>Error rows</td><td align="right">1</td></tr>`)
+	assert.Contains(t, s, `<tr><td>Credit limit sum</td><td align="right">255690.50</td></tr>`)
+	assert.Contains(t, s, `<tr><td>Credit limit average</td><td align="right">85230.17</td></tr>`)
+	assert.Contains(t, s, `<tr><td>(none)</td><td align="right">1</td></tr>`)
+	assert.Contains(t, s, `<tr><td>31</td><td align="right">2</td></tr>`)
+	assert.Contains(t, s, `<tr><td>45</td><td align="right">1</td></tr>`)
+}
+
+func TestSummaryHtml_EmptyRead_ZeroTotals(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewSummaryProducer(&testReader{})
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `<tr><td>Rows</td><td align="right">0</td></tr>`)
+	assert.Contains(t, buf.String(), `<tr><td>Credit limit average</td><td align="right">0.00</td></tr>`)
+}
+
+func TestSummaryHtml_ReadError_ErrorReturnedAndNothingWritten(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewSummaryProducer(&testReader{err: errors.New("must read, but won't")})
+	err := p.HTML(&buf, "name")
+
+	assert.EqualError(t, err, "must read, but won't")
+	assert.Empty(t, buf.String())
+}
+
+func TestSummaryHtml_ReadPanic_ErrorReturnedAndNothingWritten(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewSummaryProducer(&testReader{panic: "it-happens"})
+	err := p.HTML(&buf, "name")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "it-happens")
+	assert.Empty(t, buf.String())
+}
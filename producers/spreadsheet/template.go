@@ -0,0 +1,73 @@
+package spreadsheet
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// HelperFuncs are functions available to every template of Producer
+// in addition to those passed to NewProducerWithTemplate:
+//
+//	fmtMoney formats a number with two decimals and thousands separated
+//	by commas, e.g. "1234.5" becomes "1,234.50". Anything that isn't
+//	a number is returned as is.
+//
+//	truncate cuts a string to at most the specified number of runes,
+//	e.g. {{.Name | truncate 10}}.
+var HelperFuncs = template.FuncMap{
+	"fmtMoney": fmtMoney,
+	"truncate": truncate,
+}
+
+// NewProducerWithTemplate creates and initializes a new instance of
+// spreadsheet Producer that renders HTML output by the specified template
+// body instead of the default one. The body is executed with the same
+// data as the default template and may use its "header", "row" and
+// "script" templates. The specified funcs are registered before parsing,
+// so the body can call them along with HelperFuncs, which they override.
+// Functions used by the default templates, e.g. "age", can't be
+// overridden. It panics if the body can't be parsed.
+func NewProducerWithTemplate(reader Reader, body string, funcs template.FuncMap) *Producer {
+	p := NewProducer(reader)
+	p.funcs = funcs
+	p.setBody(body)
+	return p
+}
+
+// fmtMoney formats the specified number with two decimals and
+// thousands separated by commas.
+func fmtMoney(s string) string {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return s
+	}
+	digits := strconv.FormatFloat(v, 'f', 2, 64)
+	sign := ""
+	if digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+	whole, frac := digits[:len(digits)-3], digits[len(digits)-3:]
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(frac)
+	return b.String()
+}
+
+// truncate cuts the specified string to at most n runes.
+func truncate(n int, s string) string {
+	if n < 0 {
+		n = 0
+	}
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,72 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProducerWithTemplate_ProvidedFunc_CalledByTemplate(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Jamie Stewart"}, {Name: "Mike Leon"}}}
+	body := `{{with .First}}{{upper .Name}};{{end}}{{range .Rows}}{{upper .Name}};{{end}}`
+	var buf bytes.Buffer
+
+	p := NewProducerWithTemplate(r, body, template.FuncMap{"upper": strings.ToUpper})
+	err := p.HTML(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "JAMIE STEWART;MIKE LEON;", buf.String())
+}
+
+func TestNewProducerWithTemplate_HelperFuncs_CalledByTemplate(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Jamie Stewart", CreditLimit: "1234567.5"}}}
+	body := `{{with .First}}{{.Name | truncate 5}} {{fmtMoney .CreditLimit}}{{end}}`
+	var buf bytes.Buffer
+
+	p := NewProducerWithTemplate(r, body, nil)
+	err := p.HTML(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Jamie 1,234,567.50", buf.String())
+}
+
+func TestNewProducerWithTemplate_DefaultTemplates_Available(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Jamie Stewart"}}}
+	body := `{{with .First}}<table>{{template "row" .}}</table>{{end}}`
+	var buf bytes.Buffer
+
+	p := NewProducerWithTemplate(r, body, template.FuncMap{"age": func(Row) string { return "overridden" }})
+	err := p.HTMLWithAge(&buf, "name")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<td>Jamie Stewart</td>")
+	assert.NotContains(t, buf.String(), "overridden")
+}
+
+func TestNewProducerWithTemplate_InvalidBody_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewProducerWithTemplate(&testReader{}, "{{upper}}", nil) })
+}
+
+func TestFmtMoney_Numbers_Formatted(t *testing.T) {
+	cases := map[string]string{
+		"0":         "0.00",
+		"999":       "999.00",
+		"1000":      "1,000.00",
+		" 54.5 ":    "54.50",
+		"-1234567":  "-1,234,567.00",
+		"100000.25": "100,000.25",
+		"n/a":       "n/a",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, fmtMoney(in), "in: %q", in)
+	}
+}
+
+func TestTruncate_Strings_CutToRunes(t *testing.T) {
+	assert.Equal(t, "Jürg", truncate(4, "Jürgen"))
+	assert.Equal(t, "Jürgen", truncate(10, "Jürgen"))
+	assert.Equal(t, "", truncate(-1, "Jürgen"))
+}
@@ -0,0 +1,74 @@
+package spreadsheet
+
+import "io"
+
+// Transform changes a row before it's output, e.g. masks personal data.
+// A transform that sets ErrorMessage turns the row into an error row.
+type Transform func(Row) Row
+
+// NewProducerWithTransforms creates and initializes a new instance of
+// spreadsheet Producer that passes every row read through the specified
+// transforms in their order. Error rows are output as is, including
+// those made by a transform, so the rest of transforms is skipped.
+// Filters of the Producer see transformed rows.
+func NewProducerWithTransforms(reader Reader, transforms ...Transform) *Producer {
+	p := NewProducer(reader)
+	p.transforms = transforms
+	return p
+}
+
+// withTransforms returns a filter that applies the specified
+// transforms before the filter.
+func withTransforms(filter rowFilter, transforms []Transform) rowFilter {
+	if len(transforms) == 0 {
+		return filter
+	}
+	return func(row Row) (Row, bool) {
+		for _, t := range transforms {
+			if row.ErrorMessage != nil {
+				break
+			}
+			row = t(row)
+		}
+		if filter == nil {
+			return row, true
+		}
+		return filter(row)
+	}
+}
+
+// redactedPhoneDigits is the number of trailing digits of phones
+// that Redact keeps.
+const redactedPhoneDigits = 4
+
+// Redact is a Transform that masks sensitive fields: all digits of phone
+// but the last 4 ones are replaced by asterisks and credit limit is shown
+// as "***" if it's known. Other fields are kept as is.
+func Redact(row Row) Row {
+	phone := []rune(row.Phone)
+	kept := 0
+	for i := len(phone) - 1; i >= 0; i-- {
+		if phone[i] < '0' || phone[i] > '9' {
+			continue
+		}
+		if kept < redactedPhoneDigits {
+			kept++
+			continue
+		}
+		phone[i] = '*'
+	}
+	row.Phone = string(phone)
+	if row.CreditLimit != "" {
+		row.CreditLimit = "***"
+	}
+	return row
+}
+
+// HTMLRedacted acts as HTML but masks sensitive fields by Redact after
+// transforms of the Producer, e.g. to share the output externally.
+// Error rows are output as is.
+func (p *Producer) HTMLRedacted(w io.Writer, name string) error {
+	redacted := *p
+	redacted.transforms = append(append([]Transform(nil), p.transforms...), Redact)
+	return redacted.HTML(w, name)
+}
@@ -0,0 +1,99 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func maskPhone(row Row) Row {
+	if len(row.Phone) > 4 {
+		row.Phone = strings.Repeat("*", len(row.Phone)-4) + row.Phone[len(row.Phone)-4:]
+	}
+	return row
+}
+
+func blankCreditLimit(row Row) Row {
+	row.CreditLimit = ""
+	return row
+}
+
+func TestHtml_Transforms_TransformedRowsRendered(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Phone: "020 7899381", CreditLimit: "50000"},
+		{ErrorMessage: &errMsg},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducerWithTransforms(r, maskPhone, blankCreditLimit)
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>*******9381</td>")
+	assert.NotContains(t, s, "50000")
+	assert.Contains(t, s, "oops sorry")
+}
+
+func TestHtml_TransformSetsErrorMessage_ErrorRowRenderedAndRestSkipped(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie", CreditLimit: "-1"}}}
+	reject := func(row Row) Row {
+		msg := "Negative credit limit of " + row.Name
+		return Row{ErrorMessage: &msg}
+	}
+	called := false
+	var buf bytes.Buffer
+
+	p := NewProducerWithTransforms(r, reject, func(row Row) Row { called = true; return row })
+	err := p.HTML(&buf, "name")
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "Negative credit limit of Stewart, Jamie</td>")
+	assert.False(t, called)
+}
+
+func TestHtmlSearch_Transforms_FilterSeesTransformedRows(t *testing.T) {
+	r := &testReader{rows: []Row{{Name: "Stewart, Jamie", Phone: "020 7899381"}}}
+	var buf bytes.Buffer
+
+	p := NewProducerWithTransforms(r, maskPhone)
+	err := p.HTMLSearch(&buf, "name", "7899381")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "Stewart, Jamie")
+}
+
+func TestHtmlRedacted_SensitiveFields_Masked(t *testing.T) {
+	errMsg := "oops sorry"
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47", Postcode: "3123gg", Phone: "020 7899381", CreditLimit: "50000", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", Phone: "123"},
+		{ErrorMessage: &errMsg},
+	}}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLRedacted(&buf, "name")
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Stewart, Jamie</td><td>Voorstraat 47</td><td>3123gg</td><td>*** ***9381</td>")
+	assert.Contains(t, s, ">***</td>")
+	assert.Contains(t, s, ">1982-02-01</td>")
+	assert.Contains(t, s, "<td>123</td>")
+	assert.NotContains(t, s, "50000")
+	assert.Contains(t, s, "oops sorry")
+
+	// the Producer itself is left untouched.
+	buf.Reset()
+	err = p.HTML(&buf, "name")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "020 7899381")
+}
+
+func TestRedact_EmptyFields_KeptEmpty(t *testing.T) {
+	assert.Equal(t, Row{Name: "Stewart, Jamie"}, Redact(Row{Name: "Stewart, Jamie"}))
+}
@@ -0,0 +1,98 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rules defines checks of rows made by HTMLValidated. Columns are referred
+// by their names ignoring case, e.g. "credit limit". Blank values are
+// checked only by Required.
+type Rules struct {
+	// Required lists columns that must not be blank.
+	Required []string
+	// Patterns maps columns to expressions their values must match.
+	Patterns map[string]*regexp.Regexp
+	// NumericCreditLimit requires credit limit to be a number.
+	NumericCreditLimit bool
+	// DateBirthday requires birthday to be a date in "2006-01-02" format.
+	DateBirthday bool
+}
+
+// compile resolves columns of the rules and returns a Transform that
+// turns rows failing the rules into error rows naming failed rules.
+func (rules Rules) compile() (Transform, error) {
+	type pattern struct {
+		col Column
+		re  *regexp.Regexp
+	}
+	var required []Column
+	for _, name := range rules.Required {
+		col, ok := FindColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %s in rules", name)
+		}
+		required = append(required, col)
+	}
+	var patterns []pattern
+	for name, re := range rules.Patterns {
+		col, ok := FindColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %s in rules", name)
+		}
+		patterns = append(patterns, pattern{col: col, re: re})
+	}
+	// failures are reported in the order columns are displayed.
+	order := map[string]int{}
+	for i, col := range Columns {
+		order[col.Name] = i
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return order[patterns[i].col.Name] < order[patterns[j].col.Name]
+	})
+
+	return func(row Row) Row {
+		var failed []string
+		for _, col := range required {
+			if strings.TrimSpace(col.Get(row)) == "" {
+				failed = append(failed, fmt.Sprintf("%s is required", col.Name))
+			}
+		}
+		for _, p := range patterns {
+			if v := strings.TrimSpace(p.col.Get(row)); v != "" && !p.re.MatchString(v) {
+				failed = append(failed, fmt.Sprintf("%s %q doesn't match %s", p.col.Name, v, p.re))
+			}
+		}
+		if rules.NumericCreditLimit && !row.CreditLimitValid() {
+			failed = append(failed, fmt.Sprintf("Credit Limit %q is not a number", strings.TrimSpace(row.CreditLimit)))
+		}
+		if v := strings.TrimSpace(row.Birthday); rules.DateBirthday && v != "" {
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				failed = append(failed, fmt.Sprintf("Birthday %q is not a date", v))
+			}
+		}
+		if len(failed) == 0 {
+			return row
+		}
+		msg := fmt.Sprintf("Invalid row of %s: %s", row.Name, strings.Join(failed, "; "))
+		return Row{ErrorMessage: &msg}
+	}, nil
+}
+
+// HTMLValidated acts as HTML but checks rows against the specified rules
+// after transforms of the Producer. Rows failing any rule are output as
+// error rows telling which rules failed. Unknown columns in rules cause
+// an error before anything is read.
+func (p *Producer) HTMLValidated(w io.Writer, name string, rules Rules) error {
+	validate, err := rules.compile()
+	if err != nil {
+		return err
+	}
+	validated := *p
+	validated.transforms = append(append([]Transform(nil), p.transforms...), validate)
+	return validated.HTML(w, name)
+}
@@ -0,0 +1,55 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlValidated_FailingRows_DescriptiveErrorRowsRendered(t *testing.T) {
+	r := &testReader{rows: []Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg", CreditLimit: "50000", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", Postcode: " ", CreditLimit: "lots"},
+		{Name: "Kling, Jeramie", Postcode: "3123", Birthday: "01/02/1982"},
+	}}
+	rules := Rules{
+		Required:           []string{"postcode"},
+		Patterns:           map[string]*regexp.Regexp{"Postcode": regexp.MustCompile(`^\d{4}\s*[a-zA-Z]{2}$`)},
+		NumericCreditLimit: true,
+		DateBirthday:       true,
+	}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLValidated(&buf, "name", rules)
+	assert.NoError(t, err)
+
+	s := buf.String()
+	assert.Contains(t, s, "<td>Stewart, Jamie</td>")
+	assert.Contains(t, s, "Invalid row of Leon, Mike: Postcode is required; Credit Limit &#34;lots&#34; is not a number</td>")
+	assert.Contains(t, s, "Invalid row of Kling, Jeramie: Postcode &#34;3123&#34; doesn&#39;t match ^\\d{4}\\s*[a-zA-Z]{2}$; Birthday &#34;01/02/1982&#34; is not a date</td>")
+}
+
+func TestHtmlValidated_UnknownColumn_ErrorReturned(t *testing.T) {
+	r := &testReader{}
+	var buf bytes.Buffer
+
+	p := NewProducer(r)
+	err := p.HTMLValidated(&buf, "name", Rules{Required: []string{"Nickname"}})
+
+	assert.EqualError(t, err, "Unknown column Nickname in rules")
+	assert.Empty(t, r.readName)
+	assert.Empty(t, buf.String())
+}
+
+func TestHtmlValidated_NoRules_AllRowsRendered(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := NewProducer(&testReader{rows: []Row{{Name: "Leon, Mike", CreditLimit: "lots"}}})
+	err := p.HTMLValidated(&buf, "name", Rules{})
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "<td>Leon, Mike</td>")
+}
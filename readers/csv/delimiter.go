@@ -0,0 +1,59 @@
+package csv
+
+import (
+	"bufio"
+	"io"
+	"registry-sample/producers/spreadsheet"
+	"strings"
+
+	csv_enc "encoding/csv"
+)
+
+// delimiters lists separators recognized by delimiter detection
+// in the order of preference.
+var delimiters = []rune{',', ';', '\t'}
+
+// sniffDelimiter detects the delimiter of CSV content by its header. The
+// returned reader provides the whole content including the lines read
+// to detect the delimiter. Lines starting with the comment character
+// are skipped unless comment is zero.
+func sniffDelimiter(r io.Reader, comment rune, aliases map[string]string) (io.Reader, rune) {
+	br := bufio.NewReader(r)
+	var peeked strings.Builder
+	header := ""
+	for {
+		line, err := br.ReadString('\n')
+		peeked.WriteString(line)
+		if err != nil || comment == 0 || !strings.HasPrefix(line, string(comment)) {
+			header = line
+			break
+		}
+	}
+	return io.MultiReader(strings.NewReader(peeked.String()), br), detectDelimiter(header, aliases)
+}
+
+// detectDelimiter returns the delimiter that splits the header to the
+// largest number of known columns. If it's a tie, the delimiter that
+// occurs more often in the header wins.
+func detectDelimiter(header string, aliases map[string]string) rune {
+	best, bestKnown, bestCount := delimiters[0], -1, -1
+	for _, d := range delimiters {
+		r := csv_enc.NewReader(strings.NewReader(header))
+		r.Comma = d
+		record, err := r.Read()
+		if err != nil {
+			continue
+		}
+		known := 0
+		for _, column := range record {
+			if _, ok := spreadsheet.ResolveColumn(column, aliases); ok {
+				known++
+			}
+		}
+		count := strings.Count(header, string(d))
+		if known > bestKnown || known == bestKnown && count > bestCount {
+			best, bestKnown, bestCount = d, known, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,83 @@
+package csv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// lineRecorder keeps raw lines passed through the underlying reader, so
+// that a malformed record can be reported along with its content.
+type lineRecorder struct {
+	r       io.Reader
+	first   int
+	lines   []string
+	partial []byte
+}
+
+func newLineRecorder(r io.Reader) *lineRecorder {
+	return &lineRecorder{r: r, first: 1}
+}
+
+func (lr *lineRecorder) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	data := p[:n]
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lr.partial = append(lr.partial, data[:i]...)
+		lr.lines = append(lr.lines, string(lr.partial))
+		lr.partial = lr.partial[:0]
+		data = data[i+1:]
+	}
+	lr.partial = append(lr.partial, data...)
+	return n, err
+}
+
+// line returns the raw content of the line with the specified
+// number starting from 1. Empty string is returned if the line
+// is forgotten or not read yet.
+func (lr *lineRecorder) line(num int) string {
+	i := num - lr.first
+	if i < 0 {
+		return ""
+	}
+	if i < len(lr.lines) {
+		return lr.lines[i]
+	}
+	if i == len(lr.lines) {
+		return string(lr.partial)
+	}
+	return ""
+}
+
+// forget drops lines preceding the line with the specified number.
+func (lr *lineRecorder) forget(num int) {
+	i := num - lr.first
+	if i <= 0 {
+		return
+	}
+	if i > len(lr.lines) {
+		i = len(lr.lines)
+	}
+	lr.lines = lr.lines[i:]
+	lr.first += i
+}
+
+// replay creates a recorder that reads again the recorded content starting
+// from the line with the specified number, followed by the rest of the
+// underlying reader. Lines keep their numbers.
+func (lr *lineRecorder) replay(num int) *lineRecorder {
+	var b strings.Builder
+	for i := num - lr.first; i < len(lr.lines); i++ {
+		if i >= 0 {
+			b.WriteString(lr.lines[i])
+			b.WriteByte('\n')
+		}
+	}
+	b.Write(lr.partial)
+	r := io.MultiReader(strings.NewReader(b.String()), lr.r)
+	return &lineRecorder{r: r, first: num}
+}
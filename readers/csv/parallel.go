@@ -0,0 +1,224 @@
+package csv
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"strings"
+	"sync"
+
+	csv_enc "encoding/csv"
+)
+
+// chunkRecords is the number of records parsed by a worker at once.
+const chunkRecords = 1000
+
+// chunk is a piece of CSV content that consists of whole records.
+type chunk struct {
+	// line is the number of the first line of the chunk.
+	line  int
+	lines []string
+	// failed is the number of the line that failed to be read
+	// after the chunk, or zero if there is no such line.
+	failed int
+	// result receives rows parsed from the chunk.
+	result chan chunkResult
+}
+
+// chunkResult holds rows parsed from a chunk. If last is true, rows
+// of the following chunks must not be provided.
+type chunkResult struct {
+	rows []spreadsheet.Row
+	last bool
+}
+
+// rawLine returns the line with the specified number if it's in the chunk.
+func (c *chunk) rawLine(num int) string {
+	if i := num - c.line; i >= 0 && i < len(c.lines) {
+		return c.lines[i]
+	}
+	return ""
+}
+
+// recordReader splits CSV content to raw records. A record may span
+// several lines if a quoted field contains line breaks.
+type recordReader struct {
+	r       *bufio.Reader
+	comment rune
+	line    int
+}
+
+// next returns raw lines of the next record. Blank and commented lines
+// are returned as separate records since the parser skips them anyway.
+// If reading fails, the incomplete record is dropped.
+func (rr *recordReader) next() ([]string, error) {
+	var lines []string
+	quotes := 0
+	for {
+		line, err := rr.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line != "" {
+			lines = append(lines, line)
+			if len(lines) > 1 || rr.comment == 0 || !strings.HasPrefix(line, string(rr.comment)) {
+				quotes += strings.Count(line, `"`)
+			}
+		}
+		if err == io.EOF && len(lines) == 0 {
+			return nil, io.EOF
+		}
+		if err == io.EOF || quotes%2 == 0 {
+			rr.line += len(lines)
+			return lines, nil
+		}
+	}
+}
+
+// readParallel reads CSV content parsing chunks of records by a pool
+// of workers. Rows are provided in the order of records. Shift is the
+// number of lines preceding the content of the file, e.g. a sidecar header.
+func (rd Reader) readParallel(src io.Reader, comma rune, shift int, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	rr := &recordReader{r: bufio.NewReader(src), comment: rd.comment, line: -shift}
+	lt, err := rd.readParallelLayout(rr, comma)
+	if err != nil {
+		if err != io.EOF {
+			// if we can't read layout, we can't read the entire file.
+			log.Println("[CSV]", err)
+			rows <- spreadsheet.Row{ErrorMessage: &columnParseError}
+		}
+		return
+	}
+	if !rd.checkLayout(lt, rows) {
+		return
+	}
+
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(quit)
+
+	jobs := make(chan *chunk)
+	ordered := make(chan *chunk, rd.workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		defer close(ordered)
+		rd.splitChunks(rr, jobs, ordered, quit)
+	}()
+	for i := 0; i < rd.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				c.result <- rd.parseChunk(c, lt, comma)
+			}
+		}()
+	}
+
+	for c := range ordered {
+		var res chunkResult
+		select {
+		case <-stop:
+			return
+		case res = <-c.result:
+		}
+		for _, row := range res.rows {
+			select {
+			case <-stop:
+				return
+			case rows <- row:
+			}
+		}
+		if res.last {
+			return
+		}
+	}
+}
+
+// readParallelLayout reads layout from the first record.
+func (rd Reader) readParallelLayout(rr *recordReader, comma rune) (layout, error) {
+	for {
+		lines, err := rr.next()
+		if err != nil {
+			return nil, err
+		}
+		r := rd.newParser(strings.NewReader(strings.Join(lines, "")), comma)
+		lt, err := readLayout(r, rd.aliases, rd.extra)
+		if err != io.EOF {
+			return lt, err
+		}
+		// the record is blank or commented, so look for the next one.
+	}
+}
+
+// splitChunks reads records into chunks and passes them to workers through
+// jobs and to the assembler through ordered in the same order.
+func (rd Reader) splitChunks(rr *recordReader, jobs, ordered chan<- *chunk, quit <-chan struct{}) {
+	for {
+		c := &chunk{line: rr.line + 1, result: make(chan chunkResult, 1)}
+		var err error
+		for i := 0; i < rd.chunk; i++ {
+			var lines []string
+			if lines, err = rr.next(); err != nil {
+				break
+			}
+			c.lines = append(c.lines, lines...)
+		}
+		if err != nil && err != io.EOF {
+			log.Println("[CSV]", err)
+			c.failed = rr.line + 1
+		}
+		if len(c.lines) == 0 && c.failed == 0 {
+			return
+		}
+
+		select {
+		case <-quit:
+			return
+		case ordered <- c:
+		}
+		select {
+		case <-quit:
+			return
+		case jobs <- c:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseChunk parses records of the chunk in the same way as Read does.
+func (rd Reader) parseChunk(c *chunk, lt layout, comma rune) chunkResult {
+	r := rd.newParser(strings.NewReader(strings.Join(c.lines, "")), comma)
+	r.FieldsPerRecord = len(lt)
+
+	var res chunkResult
+	for {
+		row, _, err := readRow(r, lt)
+		if err == io.EOF {
+			if c.failed > 0 {
+				res.rows = append(res.rows, lt.invalidRow(c.failed, ""))
+				res.last = true
+			}
+			return res
+		}
+		if err != nil {
+			log.Println("[CSV]", err)
+			line := c.line + len(c.lines) - 1
+			parseErr, ok := err.(*csv_enc.ParseError)
+			if ok {
+				line = c.line + parseErr.StartLine - 1
+			}
+			// lenient readers are never parallel, so
+			// the first malformed record ends reading.
+			res.rows = append(res.rows, lt.invalidRow(line, c.rawLine(line)))
+			res.last = true
+			return res
+		}
+		res.rows = append(res.rows, rd.finishRow(row))
+	}
+}
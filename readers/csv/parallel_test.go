@@ -0,0 +1,135 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeLargeCSV generates content with the specified number of rows
+// mixing in multiline records, blank lines and wrong field counts.
+func makeLargeCSV(rows int) string {
+	var b strings.Builder
+	b.WriteString("Name,Address,Postcode,Phone,Credit Limit,Birthday\n")
+	for i := 0; i < rows; i++ {
+		switch i % 10 {
+		case 3:
+			fmt.Fprintf(&b, "\"Name %d\",\"Line 1\nLine 2\",%dAA,020 %07d,%d,01/02/1982\n", i, i, i, i)
+		case 5:
+			b.WriteString("\n")
+		case 7:
+			fmt.Fprintf(&b, "\"Name, %d\",Street %d,%dAA\n", i, i, i)
+		default:
+			fmt.Fprintf(&b, "\"Name, %d\",Street %d,%dAA,020 %07d,%d,03/11/1967\n", i, i, i, i, i)
+		}
+	}
+	return b.String()
+}
+
+func readAllRows(r *Reader) ([]spreadsheet.Row, error) {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received, <-confirm
+}
+
+func TestReaderReadParallel_LargeFile_ExpectSameRowsAsSequential(t *testing.T) {
+	content := makeLargeCSV(1000)
+	expected, err := readAllRows(NewReader(loader.NewTest(content)))
+	assert.NoError(t, err)
+
+	for _, workers := range []int{1, 3, 8} {
+		for _, size := range []int{1, 7, chunkRecords} {
+			r := NewReaderParallel(loader.NewTest(content), workers)
+			r.chunk = size
+			received, err := readAllRows(r)
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, received, "workers %d, chunk %d", workers, size)
+		}
+	}
+}
+
+func TestReaderReadParallel_MalformedLine_ExpectSameRowsAsSequential(t *testing.T) {
+	content := "Name,Address\n" +
+		"\"Stewart, Jamie\",Voorstraat 47\n" +
+		"\"Leon, Mike\"x,Dorpsplein 5A\n" +
+		"\"Smith, John\",Dorpsplein 5B\n"
+
+	par := NewReaderParallel(loader.NewTest(content), 2)
+	par.chunk = 1
+	expected, _ := readAllRows(NewReader(loader.NewTest(content)))
+	received, _ := readAllRows(par)
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderReadParallel_ReadErrorAfterContent_ExpectRowsAndError(t *testing.T) {
+	ld := loader.NewTestReadErrorAfter(
+		"Name,Address\n"+
+			"\"Stewart, Jamie\",Voorstraat 47\n",
+		errors.New("connection lost"))
+
+	r := NewReaderParallel(ld, 2)
+	received, _ := readAllRows(r)
+
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, "Stewart, Jamie", received[0].Name)
+		assert.NotNil(t, received[1].ErrorMessage)
+		assert.Equal(t, "Invalid row 3", *received[1].ErrorMessage)
+	}
+	assert.True(t, ld.ReaderClosed)
+}
+
+func TestReaderReadParallel_Stop_ExpectReadStopped(t *testing.T) {
+	ld := loader.NewTest(makeLargeCSV(1000))
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	r := NewReaderParallel(ld, 4)
+	r.chunk = 10
+	go func() {
+		defer close(done)
+		r.Read("name1", confirm, rows, stop)
+	}()
+
+	<-rows
+	close(stop)
+	<-done
+	assert.True(t, ld.ReaderClosed)
+}
+
+func benchmarkRead(b *testing.B, newReader func(ld loader.Interface) *Reader) {
+	content := makeLargeCSV(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readAllRows(newReader(loader.NewTest(content)))
+	}
+}
+
+func BenchmarkReaderRead_Sequential(b *testing.B) {
+	benchmarkRead(b, func(ld loader.Interface) *Reader {
+		return NewReader(ld)
+	})
+}
+
+func BenchmarkReaderRead_Parallel(b *testing.B) {
+	benchmarkRead(b, func(ld loader.Interface) *Reader {
+		return NewReaderParallel(ld, 4)
+	})
+}
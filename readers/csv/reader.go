@@ -1,43 +1,265 @@
 package csv
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
 	"registry-sample/readers/loader"
 	"strings"
-	"time"
 
 	csv_enc "encoding/csv"
 )
 
 var (
 	columnParseError = "Unable to parse columns"
-	rowReadError     = "Invalid row"
 )
 
-// layout defines column indices for a CSV file.
-type layout struct {
-	name        int
-	address     int
-	postcode    int
-	phone       int
-	creditLimit int
-	birthday    int
+// layout defines known columns of a CSV file by their indices.
+// Indices of unknown columns hold a zero Column unless the columns
+// are kept as extra fields.
+type layout []spreadsheet.Column
+
+// invalidRow acts as spreadsheet.InvalidRow but also sets extra
+// fields with blank values so that the row spans all columns.
+func (lt layout) invalidRow(line int, raw string) spreadsheet.Row {
+	row := spreadsheet.InvalidRow(line, raw)
+	for _, col := range lt {
+		if col.Set != nil {
+			col.Set(&row, "")
+		}
+	}
+	return row
+}
+
+// extraColumn creates a column that keeps values of an unknown
+// column with the specified header in Row.Extra.
+func extraColumn(header string) spreadsheet.Column {
+	return spreadsheet.Column{
+		Name: header,
+		Set: func(row *spreadsheet.Row, value string) {
+			row.Extra = append(row.Extra, spreadsheet.Field{Name: header, Value: value})
+		},
+	}
 }
 
 // Reader allows to read comma-separated .csv files.
 type Reader struct {
-	ld loader.Interface
+	loader.Source
+	ld       loader.Interface
+	lenient  bool
+	comment  rune
+	aliases  map[string]string
+	phone    func(string) string
+	postcode func(string) string
+	credit   func(string) string
+	sniff    bool
+	workers  int
+	chunk    int
+	trim     bool
+	extra    bool
+	sidecar  bool
+	required []string
+}
+
+// Option configures optional behaviour of Reader.
+type Option func(*Reader)
+
+// WithPhoneNormalizer makes Reader pass every non-empty phone through
+// the specified function, e.g. spreadsheet.NormalizePhone.
+func WithPhoneNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.phone = normalize
+	}
+}
+
+// WithExtraColumns makes Reader keep values of unknown columns in
+// Row.Extra under their original headers instead of dropping them.
+func WithExtraColumns() Option {
+	return func(rd *Reader) {
+		rd.extra = true
+	}
+}
+
+// WithSidecarHeader makes Reader take the header from name+".hdr" file,
+// if there is one, when the first line of a .csv file has no known
+// columns. Such a line is read as a row then. Line numbers in error rows
+// count the header as the first line anyway.
+func WithSidecarHeader() Option {
+	return func(rd *Reader) {
+		rd.sidecar = true
+	}
+}
+
+// WithPostcodeNormalizer makes Reader pass every non-empty postcode
+// through the specified function, e.g. spreadsheet.NormalizePostcode.
+func WithPostcodeNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.postcode = normalize
+	}
+}
+
+// WithCreditLimitNormalizer makes Reader pass every non-empty credit
+// limit through the specified function, e.g. spreadsheet.NormalizeCreditLimit.
+func WithCreditLimitNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.credit = normalize
+	}
+}
+
+func init() {
+	readers.Register("csv", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
 }
 
 // NewReader creates and initializes a new .csv spreadsheet reader.
-func NewReader(ld loader.Interface) *Reader {
-	return &Reader{ld: ld}
+// The reader stops at the first malformed line.
+func NewReader(ld loader.Interface, opts ...Option) *Reader {
+	rd := &Reader{Source: loader.NewSource(ld, ".csv"), ld: ld}
+	for _, opt := range opts {
+		opt(rd)
+	}
+	return rd
 }
 
-func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+// NewReaderFromReader creates and initializes a new .csv spreadsheet
+// reader that reads the specified stream, e.g. os.Stdin, rather than
+// loading files. The name passed to Read is used only as a title. The
+// stream can be read only once.
+func NewReaderFromReader(r io.Reader, opts ...Option) *Reader {
+	return NewReader(loader.NewStream(r), opts...)
+}
+
+// NewReaderLenient creates and initializes a new .csv spreadsheet reader
+// that skips malformed lines. An error row is sent for every such line
+// and reading is resumed from the next one. It holds for quote errors
+// too: lines that follow an unbalanced quote are read as rows on their
+// own rather than as a part of the malformed one.
+func NewReaderLenient(ld loader.Interface, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.lenient = true
+	return rd
+}
+
+// NewReaderWithComment creates and initializes a new .csv spreadsheet
+// reader that skips lines beginning with the specified comment
+// character, both before the header and between rows.
+func NewReaderWithComment(ld loader.Interface, comment rune, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.comment = comment
+	return rd
+}
+
+// NewReaderWithAliases creates and initializes a new .csv spreadsheet
+// reader that maps headers to known columns through the specified
+// aliases, e.g. "Zip" to "Postcode". Keys are source headers and
+// values are canonical column names.
+func NewReaderWithAliases(ld loader.Interface, aliases map[string]string, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.aliases = aliases
+	return rd
+}
+
+// NewReaderAutoDelimiter creates and initializes a new .csv spreadsheet
+// reader that detects whether values are separated by commas, semicolons
+// or tabs. The separator that gives the most known columns in the header
+// is chosen.
+func NewReaderAutoDelimiter(ld loader.Interface, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.sniff = true
+	return rd
+}
+
+// NewReaderTrim creates and initializes a new .csv spreadsheet reader
+// that trims spaces around values, e.g. in "a, b, c" content.
+func NewReaderTrim(ld loader.Interface, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.trim = true
+	return rd
+}
+
+// NewReaderStrictColumns creates and initializes a new .csv spreadsheet
+// reader that requires the specified columns to be present in the header.
+// If any of them is missing, the only error row naming missing columns is
+// sent. If required is empty, all known columns are required. Unknown
+// column names cause a panic.
+func NewReaderStrictColumns(ld loader.Interface, required []string, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	if len(required) == 0 {
+		for _, col := range spreadsheet.Columns {
+			rd.required = append(rd.required, col.Name)
+		}
+		return rd
+	}
+	for _, name := range required {
+		col, ok := spreadsheet.FindColumn(name)
+		if !ok {
+			panic(fmt.Sprintf("Unknown required column %s", name))
+		}
+		rd.required = append(rd.required, col.Name)
+	}
+	return rd
+}
+
+// NewReaderParallel creates and initializes a new .csv spreadsheet reader
+// that parses chunks of records by the specified number of workers. It
+// speeds up reading of large files, while rows are provided in the order
+// of records anyway.
+func NewReaderParallel(ld loader.Interface, workers int, opts ...Option) *Reader {
+	if workers <= 0 {
+		panic(fmt.Sprintf("Invalid number of workers %d", workers))
+	}
+	rd := NewReader(ld, opts...)
+	rd.workers = workers
+	rd.chunk = chunkRecords
+	return rd
+}
+
+// Raw opens the .csv file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".csv")
+}
+
+// Layout returns indices of columns detected in the header of the .csv
+// file with the specified name by their names. Unknown columns are
+// included only if they are kept as extra fields. Rows are not read.
+func (rd Reader) Layout(name string) (interface{}, error) {
 	f, err := rd.ld.Load(name + ".csv")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	comma := ','
+	if rd.sniff {
+		src, comma = sniffDelimiter(f, rd.comment, rd.aliases)
+	}
+	if rd.sidecar {
+		if src, _, err = rd.withSidecarHeader(context.Background(), name, src, comma); err != nil {
+			return nil, err
+		}
+	}
+	lt, err := readLayout(rd.newParser(src, comma), rd.aliases, rd.extra)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	result := map[string]int{}
+	for i, col := range lt {
+		if col.Set != nil {
+			result[col.Name] = i
+		}
+	}
+	return result, nil
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".csv")
 	if err != nil {
 		confirm <- err
 		return
@@ -45,8 +267,30 @@ func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet
 	defer f.Close()
 	confirm <- nil
 
-	r := csv_enc.NewReader(f)
-	lt, err := readLayout(r)
+	var src io.Reader = f
+	comma := ','
+	if rd.sniff {
+		src, comma = sniffDelimiter(f, rd.comment, rd.aliases)
+	}
+	// shift is the number of lines preceding the content of the file.
+	shift := 0
+	if rd.sidecar {
+		if src, shift, err = rd.withSidecarHeader(ctx, name, src, comma); err != nil {
+			// if we can't read layout, we can't read the entire file.
+			log.Println("[CSV]", err)
+			rows <- spreadsheet.Row{ErrorMessage: &columnParseError}
+			return
+		}
+	}
+
+	if rd.workers > 0 {
+		rd.readParallel(src, comma, shift, rows, stop)
+		return
+	}
+
+	lr := newLineRecorder(src)
+	r := rd.newParser(lr, comma)
+	lt, err := readLayout(r, rd.aliases, rd.extra)
 	if err != nil {
 		if err != io.EOF {
 			// if we can't read layout, we can't read the entire file.
@@ -55,92 +299,176 @@ func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet
 		}
 		return
 	}
+	if !rd.checkLayout(lt, rows) {
+		return
+	}
 
+	line := 1
+	// offset is the number of lines preceding the parser's first line.
+	offset := 0
 	for {
 		select {
 		case <-stop:
 			return
 		default:
-			row, err := readRow(r, lt)
+			row, record, err := readRow(r, lt)
 			if err == io.EOF {
 				return
 			}
 			if err != nil {
 				log.Println("[CSV]", err)
-				rows <- spreadsheet.Row{ErrorMessage: &rowReadError}
+				parseErr, ok := err.(*csv_enc.ParseError)
+				if ok {
+					line = offset + parseErr.StartLine
+				} else {
+					line++
+				}
+				rows <- lt.invalidRow(line-shift, lr.line(line))
+				if ok && rd.lenient {
+					if parseErr.Line > parseErr.StartLine {
+						// a malformed record, e.g. with an unbalanced quote,
+						// may swallow the lines that follow it, so they are
+						// parsed again as records on their own.
+						lr = lr.replay(line + 1)
+						r = rd.newParser(lr, comma)
+						offset = line
+						continue
+					}
+					lr.forget(offset + parseErr.Line + 1)
+					continue
+				}
 				return
 			}
-			rows <- row
+			// quoted fields may span lines, so the record's
+			// last line is counted from its last field.
+			line = offset + recordEnd(r, record)
+			lr.forget(line + 1)
+			rows <- rd.finishRow(row)
+		}
+	}
+}
+
+// checkLayout makes sure that the layout has all required columns.
+// Otherwise, an error row naming missing columns is sent and false
+// is returned.
+func (rd Reader) checkLayout(lt layout, rows chan<- spreadsheet.Row) bool {
+	var missing []string
+	for _, name := range rd.required {
+		found := false
+		for _, col := range lt {
+			if col.Get != nil && col.Name == name {
+				found = true
+				break
+			}
 		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return true
 	}
+	msg := fmt.Sprintf("Missing columns: %s", strings.Join(missing, ", "))
+	log.Println("[CSV]", msg)
+	rows <- spreadsheet.Row{ErrorMessage: &msg}
+	return false
 }
 
-func readLayout(r *csv_enc.Reader) (layout, error) {
-	lt := layout{
-		name:        -1,
-		address:     -1,
-		postcode:    -1,
-		phone:       -1,
-		creditLimit: -1,
-		birthday:    -1,
+// newParser creates CSV parser of the specified content
+// configured according to the reader.
+func (rd Reader) newParser(r io.Reader, comma rune) *csv_enc.Reader {
+	parser := csv_enc.NewReader(r)
+	parser.Comma = comma
+	parser.Comment = rd.comment
+	parser.TrimLeadingSpace = rd.trim
+	if rd.lenient {
+		// records are checked one by one, so the number of
+		// fields of the first one isn't enforced on the rest.
+		parser.FieldsPerRecord = -1
+	}
+	return parser
+}
+
+// finishRow applies processing to a row that is read.
+func (rd Reader) finishRow(row spreadsheet.Row) spreadsheet.Row {
+	if rd.trim {
+		for _, col := range spreadsheet.Columns {
+			col.Set(&row, strings.TrimSpace(col.Get(row)))
+		}
+		for i := range row.Extra {
+			row.Extra[i].Value = strings.TrimSpace(row.Extra[i].Value)
+		}
+	}
+	row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, "02/01/2006")
+	if rd.phone != nil && row.Phone != "" {
+		row.Phone = rd.phone(row.Phone)
 	}
+	if rd.postcode != nil && row.Postcode != "" {
+		row.Postcode = rd.postcode(row.Postcode)
+	}
+	if rd.credit != nil && row.CreditLimit != "" {
+		row.CreditLimit = rd.credit(row.CreditLimit)
+	}
+	return row
+}
 
+// readLayout reads layout from the header. If extra is true,
+// unknown columns are kept as extra fields.
+func readLayout(r *csv_enc.Reader, aliases map[string]string, extra bool) (layout, error) {
 	record, err := r.Read()
 	if err != nil {
-		return lt, err
+		return nil, err
 	}
 
+	lt := make(layout, len(record))
+	seen := map[string]bool{}
 	for i, column := range record {
-		key := strings.ToLower(column)
-		switch key {
-		case "name":
-			lt.name = i
-		case "address":
-			lt.address = i
-		case "postcode":
-			lt.postcode = i
-		case "phone":
-			lt.phone = i
-		case "credit limit":
-			lt.creditLimit = i
-		case "birthday":
-			lt.birthday = i
+		col, ok := spreadsheet.ResolveColumn(column, aliases)
+		if !ok {
+			if extra {
+				lt[i] = extraColumn(column)
+			}
+			continue
 		}
+		// the first of duplicated columns is used.
+		if seen[col.Name] {
+			log.Printf("[CSV] Duplicate column %s at position %d is ignored", col.Name, i+1)
+			continue
+		}
+		seen[col.Name] = true
+		lt[i] = col
 	}
 	return lt, nil
 }
 
-func readRow(r *csv_enc.Reader, lt layout) (spreadsheet.Row, error) {
+func readRow(r *csv_enc.Reader, lt layout) (spreadsheet.Row, []string, error) {
 	row := spreadsheet.Row{}
 
 	record, err := r.Read()
 	if err != nil && !isCsvParseError(err) {
-		return row, err
+		return row, nil, err
 	}
 
-	if lt.name >= 0 && lt.name < len(record) {
-		row.Name = record[lt.name]
-	}
-	if lt.address >= 0 && lt.address < len(record) {
-		row.Address = record[lt.address]
-	}
-	if lt.postcode >= 0 && lt.postcode < len(record) {
-		row.Postcode = record[lt.postcode]
-	}
-	if lt.phone >= 0 && lt.phone < len(record) {
-		row.Phone = record[lt.phone]
-	}
-	if lt.creditLimit >= 0 && lt.creditLimit < len(record) {
-		row.CreditLimit = record[lt.creditLimit]
-	}
-	if lt.birthday >= 0 && lt.birthday < len(record) {
-		if t, err := time.Parse("02/01/2006", record[lt.birthday]); err == nil {
-			row.Birthday = t.Format("2006-01-02")
-		} else {
-			row.Birthday = record[lt.birthday]
+	for i, col := range lt {
+		if col.Set == nil {
+			continue
 		}
+		// missing values are blank to keep extra fields aligned.
+		value := ""
+		if i < len(record) {
+			value = record[i]
+		}
+		col.Set(&row, value)
 	}
-	return row, nil
+	return row, record, nil
+}
+
+// recordEnd returns the number of the last line of the record that
+// the parser has just read.
+func recordEnd(r *csv_enc.Reader, record []string) int {
+	last := len(record) - 1
+	line, _ := r.FieldPos(last)
+	return line + strings.Count(record[last], "\n")
 }
 
 func isCsvParseError(err error) bool {
@@ -1,11 +1,17 @@
 package csv
 
 import (
+	"bytes"
 	"errors"
+	"log"
+	"os"
 	"registry-sample/producers/spreadsheet"
 	"registry-sample/readers/loader"
+	"strings"
 	"testing"
 
+	csv_enc "encoding/csv"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -194,3 +200,638 @@ func TestReaderRead_LoadOk_ExpectReaderClosed(t *testing.T) {
 
 	assert.True(t, ld.ReaderClosed)
 }
+
+func TestReaderRead_MalformedLine_ExpectReadStopped(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, \"Mike\",Dorpsplein 5A\n" +
+			"\"Kling, Jeramie\",Mendelssohnstraat 25d\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, "Stewart, Jamie", received[0].Name)
+	assert.NotNil(t, received[1].ErrorMessage)
+}
+
+func TestReaderReadLenient_MalformedLine_ExpectReadResumed(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, \"Mike\",Dorpsplein 5A\n" +
+			"\"Kling, Jeramie\",Mendelssohnstraat 25d\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReaderLenient(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	if assert.Len(t, received, 3) {
+		assert.Equal(t, spreadsheet.Row{Name: "Stewart, Jamie", Address: "Voorstraat 47"}, received[0])
+		if assert.NotNil(t, received[1].ErrorMessage) {
+			assert.Equal(t, `Invalid row 3: "\"Leon, \"Mike\",Dorpsp..."`, *received[1].ErrorMessage)
+		}
+		assert.Equal(t, spreadsheet.Row{Name: "Kling, Jeramie", Address: "Mendelssohnstraat 25d"}, received[2])
+	}
+}
+
+func TestReaderReadLenient_UnbalancedQuote_ExpectFollowingRowsRead(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, Mike,Dorpsplein 5A\n" +
+			"\"Kling, Jeramie\",Mendelssohnstraat 25d\n" +
+			"\"Nordberg, Taylor\",Kerkstraat 1\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+		{Name: "Kling, Jeramie", Address: "Mendelssohnstraat 25d"},
+		{Name: "Nordberg, Taylor", Address: "Kerkstraat 1"},
+	}
+
+	received, _ := readAllRows(NewReaderLenient(ld))
+
+	if assert.Len(t, received, 4) && assert.NotNil(t, received[1].ErrorMessage) {
+		assert.Equal(t, `Invalid row 3: "\"Leon, Mike,Dorpsple..."`, *received[1].ErrorMessage)
+		assert.Equal(t, expected, []spreadsheet.Row{received[0], received[2], received[3]})
+	}
+}
+
+func TestReaderReadLenient_SeveralQuoteErrors_ExpectLineNumbersKept(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Leon, Mike,Dorpsplein 5A\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Kling\" Jeramie,Mendelssohnstraat 25d\n" +
+			"\"Nordberg, Taylor,Kerkstraat 1\n" +
+			"\"Dekker, Anna\",\"Dorpsplein 5\n" +
+			"3123gg\"\n")
+
+	received, _ := readAllRows(NewReaderLenient(ld))
+
+	var errs []string
+	for _, row := range received {
+		if row.ErrorMessage != nil {
+			errs = append(errs, (*row.ErrorMessage)[:len("Invalid row N")])
+		}
+	}
+	assert.Equal(t, []string{"Invalid row 2", "Invalid row 4", "Invalid row 5"}, errs)
+	assert.Contains(t, received, spreadsheet.Row{Name: "Stewart, Jamie", Address: "Voorstraat 47"})
+	assert.Contains(t, received, spreadsheet.Row{Name: "Dekker, Anna", Address: "Dorpsplein 5\n3123gg"})
+}
+
+func TestReaderReadLenient_ReadError_ExpectReadStopped(t *testing.T) {
+	ld := loader.NewTestReadError(errors.New("wrong content"))
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReaderLenient(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Len(t, received, 1)
+	assert.NotNil(t, received[0].ErrorMessage)
+}
+
+func TestReaderRead_MalformedMultilineRecord_ExpectStartLineInError(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",\"Voorstraat 47\n" +
+			"3123gg\",020\n" +
+			"\"Leon\" Mike,Dorpsplein 5A\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReaderLenient(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	if assert.Len(t, received, 2) && assert.NotNil(t, received[1].ErrorMessage) {
+		assert.Equal(t, `Invalid row 4: "\"Leon\" Mike,Dorpsple..."`, *received[1].ErrorMessage)
+	}
+}
+
+func TestReaderRead_WithPhoneNormalizer_ExpectPhoneNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Phone\n" +
+			"\"Stewart, Jamie\",020 7899381\n" +
+			"\"Nordberg, Taylor\",+1 709 880038\n" +
+			"\"Leon, Mike\",n/a\n" +
+			"\"Kling, Jeramie\",\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld, WithPhoneNormalizer(spreadsheet.NormalizePhone))
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var phones []string
+	for row := range rows {
+		phones = append(phones, row.Phone)
+	}
+
+	assert.Equal(t, []string{"0207899381", "+1709880038", "n/a (invalid)", ""}, phones)
+}
+
+func TestReaderRead_WithPostcodeNormalizer_ExpectPostcodeNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Postcode\n" +
+			"\"Stewart, Jamie\",3123gg\n" +
+			"\"Leon, Mike\",4532 AA\n" +
+			"\"Gibson, Mal\",91455\n" +
+			"\"Kling, Jeramie\",\n" +
+			"\"Nordberg, Taylor\",unknown\n")
+
+	received, _ := readAllRows(NewReader(ld, WithPostcodeNormalizer(spreadsheet.NormalizePostcode)))
+
+	var postcodes []string
+	for _, row := range received {
+		postcodes = append(postcodes, row.Postcode)
+	}
+	assert.Equal(t, []string{"3123 GG", "4532 AA", "91455", "", "unknown (invalid)"}, postcodes)
+}
+
+func TestReaderRead_WithCreditLimitNormalizer_ExpectCreditLimitNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Credit Limit\n" +
+			"\"Stewart, Jamie\",\"$1,234.5\"\n" +
+			"\"Leon, Mike\",50000\n" +
+			"\"Kling, Jeramie\",\n" +
+			"\"Nordberg, Taylor\",N/A\n")
+
+	received, _ := readAllRows(NewReader(ld, WithCreditLimitNormalizer(spreadsheet.NormalizeCreditLimit)))
+
+	var limits []string
+	for _, row := range received {
+		limits = append(limits, row.CreditLimit)
+	}
+	assert.Equal(t, []string{"1234.50", "50000.00", "", "N/A"}, limits)
+}
+
+func TestReaderRead_EmailAndCompanyColumns_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Email,Address,Postcode,Phone,Credit Limit,Birthday,Company,Unknown\n" +
+			"\"Stewart, Jamie\",jamie@example.com,Voorstraat 47,3123gg,020 7899381,50000,01/02/1982,\"Acme, Inc.\",x\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := spreadsheet.Row{
+		Name:        "Stewart, Jamie",
+		Address:     "Voorstraat 47",
+		Postcode:    "3123gg",
+		Phone:       "020 7899381",
+		CreditLimit: "50000",
+		Birthday:    "1982-02-01",
+		Email:       "jamie@example.com",
+		Company:     "Acme, Inc.",
+	}
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, []spreadsheet.Row{expected}, received)
+}
+
+func TestReadLayout_SharedColumns_AllRecognized(t *testing.T) {
+	var header []string
+	for _, col := range spreadsheet.Columns {
+		header = append(header, col.Name)
+	}
+	lt, err := readLayout(csv_enc.NewReader(strings.NewReader(strings.Join(header, ",")+"\n")), nil, false)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, col := range lt {
+		names = append(names, col.Name)
+	}
+	assert.Equal(t, header, names)
+}
+
+func TestReaderRead_WithComment_ExpectCommentedLinesSkipped(t *testing.T) {
+	ld := loader.NewTest(
+		"# exported by registry\n" +
+			"# 2017-10-08\n" +
+			"Name,Postcode\n" +
+			"\"Stewart, Jamie\",3123gg\n" +
+			"# Leon, Mike moved away\n" +
+			"\"Leon, Mike\",4532 AA\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+		{Name: "Leon, Mike", Postcode: "4532 AA"},
+	}
+
+	r := NewReaderWithComment(ld, '#')
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_WithAliases_ExpectAliasedColumnsPopulated(t *testing.T) {
+	ld := loader.NewTest(
+		"Full Name,zip,DOB,Mobile,Fax\n" +
+			"\"Stewart, Jamie\",3123gg,01/02/1982,020 7899381,020 7899382\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{
+			Name:     "Stewart, Jamie",
+			Postcode: "3123gg",
+			Phone:    "020 7899381",
+			Birthday: "1982-02-01",
+		},
+	}
+
+	r := NewReaderWithAliases(ld, map[string]string{
+		"Full Name": "Name",
+		"Zip":       "postcode",
+		"DOB":       "Birthday",
+		"Mobile":    "Phone",
+	})
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_AutoDelimiter_ExpectDelimiterDetected(t *testing.T) {
+	fixtures := map[string]string{
+		"comma":     "Name,Address,Postcode\n\"Stewart; Jamie\",Voorstraat 47,3123gg\n",
+		"semicolon": "Name;Address;Postcode\nStewart, Jamie;Voorstraat 47;3123gg\n",
+		"tab":       "Name\tAddress\tPostcode\nStewart, Jamie\tVoorstraat 47\t3123gg\n",
+	}
+	names := map[string]string{
+		"comma":     "Stewart; Jamie",
+		"semicolon": "Stewart, Jamie",
+		"tab":       "Stewart, Jamie",
+	}
+
+	for kind, content := range fixtures {
+		ld := loader.NewTest(content)
+		confirm := make(chan error, 2)
+		rows := make(chan spreadsheet.Row)
+		expected := []spreadsheet.Row{
+			{Name: names[kind], Address: "Voorstraat 47", Postcode: "3123gg"},
+		}
+
+		r := NewReaderAutoDelimiter(ld)
+		go func() {
+			defer close(rows)
+			r.Read("name1", confirm, rows, nil)
+		}()
+
+		var received []spreadsheet.Row
+		for row := range rows {
+			received = append(received, row)
+		}
+
+		assert.Equal(t, expected, received, kind)
+	}
+}
+
+func TestDetectDelimiter_NoKnownColumns_MostFrequentChosen(t *testing.T) {
+	assert.Equal(t, ';', detectDelimiter("a;b;c,d\n", nil))
+	assert.Equal(t, ',', detectDelimiter("abc\n", nil))
+}
+
+func TestReaderReadTrim_PaddedFields_ExpectTrimmedValues(t *testing.T) {
+	ld := loader.NewTest(
+		"Name, Address, Postcode, Birthday\n" +
+			"\"Stewart, Jamie\",  Voorstraat 47 , \" 3123gg \", 01/02/1982 \n")
+	expected := []spreadsheet.Row{
+		{
+			Name:     "Stewart, Jamie",
+			Address:  "Voorstraat 47",
+			Postcode: "3123gg",
+			Birthday: "1982-02-01",
+		},
+	}
+
+	received, _ := readAllRows(NewReaderTrim(ld))
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_PaddedFields_ExpectValuesAsIs(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address,Postcode\n" +
+			"\"Stewart, Jamie\",  Voorstraat 47 , 3123gg \n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "  Voorstraat 47 ", Postcode: " 3123gg "},
+	}
+
+	received, _ := readAllRows(NewReader(ld))
+
+	assert.Equal(t, expected, received)
+}
+
+func TestProducerHTML_HeaderOnly_ExpectNoRecordsPlaceholder(t *testing.T) {
+	ld := loader.NewTest("Name,Address,Postcode,Phone,Credit Limit,Birthday\n")
+	var buf strings.Builder
+
+	err := spreadsheet.NewProducer(NewReader(ld)).HTML(&buf, "name1")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<title>name1</title>")
+	assert.Contains(t, buf.String(), "No records found")
+	assert.NotContains(t, buf.String(), "<table")
+}
+
+func TestReaderRead_WithExtraColumns_ExpectUnknownColumnsKept(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Region,Postcode,Segment\n" +
+			"\"Stewart, Jamie\",North,3123gg,Retail\n" +
+			"\"Leon, Mike\",South\n")
+	expected := []spreadsheet.Row{
+		{
+			Name:     "Stewart, Jamie",
+			Postcode: "3123gg",
+			Extra:    []spreadsheet.Field{{Name: "Region", Value: "North"}, {Name: "Segment", Value: "Retail"}},
+		},
+		{
+			Name:  "Leon, Mike",
+			Extra: []spreadsheet.Field{{Name: "Region", Value: "South"}, {Name: "Segment", Value: ""}},
+		},
+	}
+
+	received, _ := readAllRows(NewReader(ld, WithExtraColumns()))
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_WithoutExtraColumns_ExpectUnknownColumnsDropped(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Region,Postcode,Segment\n" +
+			"\"Stewart, Jamie\",North,3123gg,Retail\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received, _ := readAllRows(NewReader(ld))
+
+	assert.Equal(t, expected, received)
+}
+
+func TestProducerHTML_ExtraColumns_ExpectColumnsInOutput(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Region,Postcode,Segment\n" +
+			"\"Stewart, Jamie\",North,3123gg,Retail\n")
+	var buf strings.Builder
+
+	p := spreadsheet.NewProducer(NewReader(ld, WithExtraColumns()))
+	p.ShowExtraColumns(true)
+	err := p.HTML(&buf, "name1")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<td>Company</td><td>Region</td><td>Segment</td></tr>")
+	assert.Contains(t, buf.String(), "<td></td><td>North</td><td>Retail</td></tr>")
+}
+
+func TestProducerRows_ManyMalformedLines_ExpectReadStoppedAfterCap(t *testing.T) {
+	content := "Name,Address\n"
+	for i := 0; i < 100; i++ {
+		content += "Leon \"Mike\",Dorpsplein 5A\n"
+	}
+	var stats []spreadsheet.ReadStats
+
+	p := spreadsheet.NewProducer(NewReaderLenient(loader.NewTest(content)))
+	p.LimitErrorRows(5)
+	p.ReportStats(func(s spreadsheet.ReadStats) { stats = append(stats, s) })
+	rows, errc := p.Rows("name1")
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.NoError(t, <-errc)
+	if assert.Len(t, received, 6) {
+		for _, row := range received {
+			assert.NotNil(t, row.ErrorMessage)
+		}
+		assert.Equal(t, "Too many errors, read is aborted after 5 error rows", *received[5].ErrorMessage)
+	}
+	assert.Equal(t, []spreadsheet.ReadStats{{Name: "name1", Rows: 6, Stopped: true}}, stats)
+}
+
+func TestReaderReadStrictColumns_CompleteHeader_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(
+		"name,Address,Postcode\n" +
+			"\"Stewart, Jamie\",Voorstraat 47,3123gg\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47", Postcode: "3123gg"},
+	}
+
+	received, err := readAllRows(NewReaderStrictColumns(ld, []string{"Name", "postcode"}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderReadStrictColumns_MissingPostcode_ExpectErrorRowOnly(t *testing.T) {
+	for _, r := range []*Reader{
+		NewReaderStrictColumns(loader.NewTest("Name,Address,Phone\n\"Stewart, Jamie\",Voorstraat 47,020 7899381\n"), []string{"Name", "Postcode", "Phone"}),
+		NewReaderStrictColumns(loader.NewTest("Name,Address,Phone\n\"Stewart, Jamie\",Voorstraat 47,020 7899381\n"), []string{"Name", "Postcode", "Phone"}, WithExtraColumns()),
+	} {
+		received, err := readAllRows(r)
+
+		assert.NoError(t, err)
+		if assert.Len(t, received, 1) && assert.NotNil(t, received[0].ErrorMessage) {
+			assert.Equal(t, "Missing columns: Postcode", *received[0].ErrorMessage)
+		}
+	}
+}
+
+func TestReaderReadStrictColumns_NoRequired_ExpectAllColumnsRequired(t *testing.T) {
+	ld := loader.NewTest("Name,Address,Postcode,Phone,Credit Limit,Birthday\n")
+
+	received, _ := readAllRows(NewReaderStrictColumns(ld, nil))
+
+	if assert.Len(t, received, 1) && assert.NotNil(t, received[0].ErrorMessage) {
+		assert.Equal(t, "Missing columns: Email, Company", *received[0].ErrorMessage)
+	}
+}
+
+func TestReaderReadStrictColumnsParallel_MissingPostcode_ExpectErrorRowOnly(t *testing.T) {
+	r := NewReaderStrictColumns(loader.NewTest("Name,Address\n\"Stewart, Jamie\",Voorstraat 47\n"), []string{"Postcode"})
+	r.workers = 2
+	r.chunk = chunkRecords
+
+	received, _ := readAllRows(r)
+
+	if assert.Len(t, received, 1) && assert.NotNil(t, received[0].ErrorMessage) {
+		assert.Equal(t, "Missing columns: Postcode", *received[0].ErrorMessage)
+	}
+}
+
+func TestNewReaderStrictColumns_UnknownColumn_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewReaderStrictColumns(loader.NewTest(""), []string{"Nickname"}) })
+}
+
+func TestReaderRead_DuplicatedColumn_ExpectFirstColumnUsed(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address,Name,Zip\n" +
+			"\"Stewart, Jamie\",Voorstraat 47,Jamie,3123gg\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+	}
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	received, _ := readAllRows(NewReaderWithAliases(ld, map[string]string{"Zip": "Address"}))
+
+	assert.Equal(t, expected, received)
+	assert.Contains(t, logBuf.String(), "[CSV] Duplicate column Name at position 3 is ignored")
+	assert.Contains(t, logBuf.String(), "[CSV] Duplicate column Address at position 4 is ignored")
+}
+
+func TestReaderFromReader_StringReader_ExpectContentOnRows(t *testing.T) {
+	r := strings.NewReader(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, Mike\",Dorpsplein 5A\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+		{Name: "Leon, Mike", Address: "Dorpsplein 5A"},
+	}
+
+	received, err := readAllRows(NewReaderFromReader(r))
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestProducerHTML_ReaderFromReader_ExpectNameAsTitle(t *testing.T) {
+	r := strings.NewReader("Name\n\"Stewart, Jamie\"\n")
+	var buf strings.Builder
+
+	err := spreadsheet.NewProducer(NewReaderFromReader(r)).HTML(&buf, "stdin")
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "<title>stdin</title>")
+	assert.Contains(t, buf.String(), "<td>Stewart, Jamie</td>")
+}
+
+func TestReaderLayout_Header_ExpectIndicesOfKnownColumns(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Unknown,Zip,Phone\n" +
+			"\"Stewart, Jamie\",x,3123gg,020 7899381\n")
+
+	lt, err := NewReaderWithAliases(ld, map[string]string{"Zip": "Postcode"}).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"Name": 0, "Postcode": 2, "Phone": 3}, lt)
+	assert.Equal(t, "name1.csv", ld.LoadName)
+}
+
+func TestReaderLayout_WithExtraColumns_ExpectUnknownColumnsIncluded(t *testing.T) {
+	ld := loader.NewTest("Name,Unknown\n")
+
+	lt, err := NewReader(ld, WithExtraColumns()).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"Name": 0, "Unknown": 1}, lt)
+}
+
+func TestReaderRaw_LoaderLoad_ExpectCsvFileOpened(t *testing.T) {
+	ld := loader.NewTest("Name\n")
+
+	f, err := NewReader(ld).Raw("name1")
+
+	if assert.NoError(t, err) {
+		f.Close()
+	}
+	assert.Equal(t, "name1.csv", ld.LoadName)
+}
+
+func TestReaderRead_QuotedMultilineField_ExpectSingleRowAndLineNumbersKept(t *testing.T) {
+	ld := loader.NewTest(
+		"Name,Address\n" +
+			"\"Stewart, Jamie\",\"Voorstraat 47\n" +
+			"Amsterdam\"\n" +
+			"\"Leon, Mike\",Dorpsplein 5A\n" +
+			"\"Kling\" Jeramie,Mendelssohnstraat 25d\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47\nAmsterdam"},
+		{Name: "Leon, Mike", Address: "Dorpsplein 5A"},
+	}
+
+	received, _ := readAllRows(NewReader(ld))
+
+	if assert.Len(t, received, 3) && assert.NotNil(t, received[2].ErrorMessage) {
+		assert.Equal(t, expected, received[:2])
+		assert.Equal(t, `Invalid row 5: "\"Kling\" Jeramie,Mend..."`, *received[2].ErrorMessage)
+	}
+}
+
+func TestReaderRead_ReadErrorAfterMultilineField_ExpectLineAfterRecordInError(t *testing.T) {
+	ld := loader.NewTestReadErrorAfter(
+		"Name,Address\n"+
+			"\"Stewart, Jamie\",\"Voorstraat 47\n"+
+			"Amsterdam\"\n",
+		errors.New("connection lost"))
+
+	received, _ := readAllRows(NewReader(ld))
+
+	if assert.Len(t, received, 2) && assert.NotNil(t, received[1].ErrorMessage) {
+		assert.Equal(t, "Invalid row 4", *received[1].ErrorMessage)
+	}
+}
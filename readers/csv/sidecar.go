@@ -0,0 +1,61 @@
+package csv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"strings"
+)
+
+// withSidecarHeader returns the content of the .csv file with the specified
+// name preceded by the header from name+".hdr" file if the first line of
+// the content isn't a header, i.e. has no known columns. If there is no
+// such file, the content is returned as is. The number of lines preceding
+// the content is returned as well, so that line numbers of the .csv file
+// can be reported.
+func (rd Reader) withSidecarHeader(ctx context.Context, name string, src io.Reader, comma rune) (io.Reader, int, error) {
+	br := bufio.NewReader(src)
+	first, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	src = io.MultiReader(strings.NewReader(first), br)
+	if rd.isHeader(first, comma) {
+		return src, 0, nil
+	}
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".hdr")
+	if errors.Is(err, os.ErrNotExist) {
+		return src, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	header, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	if !strings.HasSuffix(header, "\n") {
+		header += "\n"
+	}
+	return io.MultiReader(strings.NewReader(header), src), 1, nil
+}
+
+// isHeader reports whether the specified line has any known column.
+func (rd Reader) isHeader(line string, comma rune) bool {
+	record, err := rd.newParser(strings.NewReader(line), comma).Read()
+	if err != nil {
+		return false
+	}
+	for _, column := range record {
+		if _, ok := spreadsheet.ResolveColumn(column, rd.aliases); ok {
+			return true
+		}
+	}
+	return false
+}
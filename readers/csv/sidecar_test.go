@@ -0,0 +1,85 @@
+package csv
+
+import (
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderRead_SidecarHeader_ExpectAllLinesReadAsRows(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name,Address",
+		"name1.csv": "\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, Mike\",Dorpsplein 5A\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+		{Name: "Leon, Mike", Address: "Dorpsplein 5A"},
+	}
+
+	received, err := readAllRows(NewReader(ld, WithSidecarHeader()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_SidecarHeaderAndMalformedRow_ExpectFileLineNumber(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name,Address\n",
+		"name1.csv": "\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, \"Mike\",Dorpsplein 5A\n",
+	}
+
+	for _, r := range []*Reader{
+		NewReader(ld, WithSidecarHeader()),
+		NewReaderParallel(ld, 2, WithSidecarHeader()),
+	} {
+		received, _ := readAllRows(r)
+
+		if assert.Len(t, received, 2) && assert.NotNil(t, received[1].ErrorMessage) {
+			assert.Equal(t, `Invalid row 2: "\"Leon, \"Mike\",Dorpsp..."`, *received[1].ErrorMessage)
+		}
+	}
+}
+
+func TestReaderRead_SidecarHeaderAndHeaderInFile_ExpectFileHeaderUsed(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Address,Name\n",
+		"name1.csv": "Name,Address\n" +
+			"\"Stewart, Jamie\",Voorstraat 47\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+	}
+
+	received, err := readAllRows(NewReader(ld, WithSidecarHeader()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_NoSidecarHeader_ExpectNoKnownColumns(t *testing.T) {
+	ld := loader.Files{
+		"name1.csv": "\"Stewart, Jamie\",Voorstraat 47\n" +
+			"\"Leon, Mike\",Dorpsplein 5A\n",
+	}
+
+	received, err := readAllRows(NewReader(ld, WithSidecarHeader()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []spreadsheet.Row{{}}, received)
+}
+
+func TestReaderLayout_SidecarHeader_ExpectSidecarColumns(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name,Address\n",
+		"name1.csv": "\"Stewart, Jamie\",Voorstraat 47\n",
+	}
+
+	lt, err := NewReader(ld, WithSidecarHeader()).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"Name": 0, "Address": 1}, lt)
+}
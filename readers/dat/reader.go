@@ -0,0 +1,155 @@
+package dat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+	"strings"
+)
+
+var (
+	columnParseError = "Unable to parse columns"
+)
+
+// separator delimits fields of .dat files.
+const separator = "|"
+
+// birthdayLayouts lists formats in which birthdays are expected.
+var birthdayLayouts = []string{"02/01/2006", "20060102"}
+
+// layout defines known columns of a .dat file by their indices.
+// Indices of unknown columns hold a zero Column.
+type layout []spreadsheet.Column
+
+// Reader allows to read pipe-delimited .dat files.
+// Rows are read in batches.
+type Reader struct {
+	loader.Source
+	ld    loader.Interface
+	batch int
+}
+
+func init() {
+	readers.Register("dat", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// NewReader creates and initializes a new .dat spreadsheet reader.
+// Lines which number of fields differs from the header are reported
+// as error rows and skipped.
+func NewReader(ld loader.Interface) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".dat"), ld: ld, batch: spreadsheet.DefaultBatchSize}
+}
+
+// NewReaderWithBatchSize creates and initializes a new .dat spreadsheet
+// reader that sends rows in batches of the specified size. Larger batches
+// are cheaper to pass but the first rows are sent later.
+func NewReaderWithBatchSize(ld loader.Interface, size int) *Reader {
+	if size <= 0 {
+		panic(fmt.Sprintf("Invalid batch size %d", size))
+	}
+	return &Reader{Source: loader.NewSource(ld, ".dat"), ld: ld, batch: size}
+}
+
+// Raw opens the .dat file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".dat")
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	spreadsheet.ReadUnbatched(rd, name, confirm, rows, stop)
+}
+
+// ReadBatches acts as Read but sends rows in batches.
+func (rd Reader) ReadBatches(name string, confirm chan<- error, batches chan<- []spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".dat")
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer f.Close()
+	confirm <- nil
+
+	rows := spreadsheet.NewRowBatcher(batches, rd.batch)
+	defer rows.Flush()
+	r := bufio.NewReader(f)
+	header, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || header == "") {
+		if err != io.EOF {
+			// if we can't read layout, we can't read the entire file.
+			log.Println("[DAT]", err)
+			rows.Add(spreadsheet.Row{ErrorMessage: &columnParseError})
+		}
+		return
+	}
+	lt := readLayout(header)
+
+	line := 1
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			record, err := r.ReadString('\n')
+			line++
+			if err != nil && err != io.EOF {
+				log.Println("[DAT]", err)
+				rows.Add(spreadsheet.InvalidRow(line, record))
+				return
+			}
+			if strings.TrimSpace(record) != "" {
+				if row, ok := readRow(record, lt); ok {
+					rows.Add(row)
+				} else {
+					rows.Add(spreadsheet.InvalidRow(line, record))
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}
+}
+
+// split splits the record to trimmed fields.
+func split(record string) []string {
+	fields := strings.Split(strings.TrimRight(record, "\r\n"), separator)
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return fields
+}
+
+func readLayout(header string) layout {
+	fields := split(header)
+	lt := make(layout, len(fields))
+	for i, column := range fields {
+		if col, ok := spreadsheet.FindColumn(column); ok {
+			lt[i] = col
+		}
+	}
+	return lt
+}
+
+// readRow reads the record by the layout. If number of fields in the
+// record differs from the layout, false is returned.
+func readRow(record string, lt layout) (spreadsheet.Row, bool) {
+	row := spreadsheet.Row{}
+	fields := split(record)
+	if len(fields) != len(lt) {
+		return row, false
+	}
+	for i, col := range lt {
+		if col.Set != nil {
+			col.Set(&row, fields[i])
+		}
+	}
+	row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, birthdayLayouts...)
+	return row, true
+}
@@ -0,0 +1,162 @@
+package dat
+
+import (
+	"errors"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(r *Reader, name string) []spreadsheet.Row {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received
+}
+
+func TestReaderRead_LoadError_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	err := <-confirm
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+}
+
+func TestReaderRead_LoadOk_ExpectNilOnConfirmed(t *testing.T) {
+	ld := loader.NewTest("Name")
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	err := <-confirm
+
+	assert.NoError(t, err)
+}
+
+func TestReaderRead_LoaderLoad_ExpectCorrectArgs(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("doesn't matter"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	<-confirm
+
+	assert.Equal(t, "name1.dat", ld.LoadName)
+}
+
+func TestReaderRead_LoadOk_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(
+		"Name | Address | Postcode | Phone | Credit Limit | Birthday\n" +
+			"Stewart, Jamie | Voorstraat 47 | 3123gg | 020 7899381 | 50000 | 01/02/1982\n" +
+			"Leon, Mike|Dorpsplein 5A|4532 AA|030 2288986|201092|19671103")
+	expected := []spreadsheet.Row{
+		{
+			Name:        "Stewart, Jamie",
+			Address:     "Voorstraat 47",
+			Postcode:    "3123gg",
+			Phone:       "020 7899381",
+			CreditLimit: "50000",
+			Birthday:    "1982-02-01",
+		}, {
+			Name:        "Leon, Mike",
+			Address:     "Dorpsplein 5A",
+			Postcode:    "4532 AA",
+			Phone:       "030 2288986",
+			CreditLimit: "201092",
+			Birthday:    "1967-11-03",
+		},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_LoadOk_ExpectOnlyKnownColumns(t *testing.T) {
+	ld := loader.NewTest(
+		"Name|Address111|Postcode|Phone222\n" +
+			"Stewart, Jamie|Voorstraat 47|3123gg|020 7899381\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_ReadError_ExpectErrorOnRows(t *testing.T) {
+	ld := loader.NewTestReadError(errors.New("wrong content"))
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Len(t, received, 1)
+	assert.NotNil(t, received[0].ErrorMessage)
+}
+
+func TestReaderRead_MalformedLine_ExpectErrorRowAndReadResumed(t *testing.T) {
+	ld := loader.NewTest(
+		"Name|Postcode\n" +
+			"Stewart, Jamie|3123gg|extra\n" +
+			"\n" +
+			"Leon, Mike|4532 AA\n")
+
+	received := readAll(NewReader(ld), "name1")
+
+	if assert.Len(t, received, 2) {
+		assert.NotNil(t, received[0].ErrorMessage)
+		assert.Equal(t, `Invalid row 2: "Stewart, Jamie|3123g..."`, *received[0].ErrorMessage)
+		assert.Equal(t, spreadsheet.Row{Name: "Leon, Mike", Postcode: "4532 AA"}, received[1])
+	}
+}
+
+func TestReaderRead_LoadOk_ExpectReaderClosed(t *testing.T) {
+	ld := loader.NewTest(
+		"Name|Postcode\n" +
+			"Stewart, Jamie|3123gg\n")
+
+	readAll(NewReader(ld), "name1")
+
+	assert.True(t, ld.ReaderClosed)
+}
+
+func TestReaderReadBatches_BatchSize_ExpectRowsInBatches(t *testing.T) {
+	ld := loader.NewTest("Name|Postcode\nKling|3123gg\nLeon|4532 AA\n\nGibson|1234\nbroken\n")
+	confirm := make(chan error, 2)
+	batches := make(chan []spreadsheet.Row)
+	go func() {
+		defer close(batches)
+		NewReaderWithBatchSize(ld, 2).ReadBatches("name1", confirm, batches, nil)
+	}()
+
+	var received [][]spreadsheet.Row
+	for batch := range batches {
+		received = append(received, batch)
+	}
+
+	if assert.Len(t, received, 2) && assert.Len(t, received[1], 2) {
+		assert.Equal(t, []spreadsheet.Row{{Name: "Kling", Postcode: "3123gg"}, {Name: "Leon", Postcode: "4532 AA"}}, received[0])
+		assert.Equal(t, spreadsheet.Row{Name: "Gibson", Postcode: "1234"}, received[1][0])
+		assert.NotNil(t, received[1][1].ErrorMessage)
+	}
+}
+
+func TestNewReaderWithBatchSize_InvalidSize_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewReaderWithBatchSize(loader.NewTest(""), 0) })
+}
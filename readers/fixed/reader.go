@@ -0,0 +1,194 @@
+package fixed
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+	"strconv"
+	"strings"
+)
+
+// birthdayLayouts lists formats in which birthdays are expected.
+var birthdayLayouts = []string{"02/01/2006", "20060102"}
+
+// Field defines position of a column in lines of a fixed-width file.
+// Start is the zero-based offset of the first rune of the column and
+// Width is the number of runes it occupies.
+type Field struct {
+	Column string
+	Start  int
+	Width  int
+}
+
+// Schema defines columns of a fixed-width file.
+type Schema []Field
+
+// column binds a field of a schema to a known column.
+type column struct {
+	spreadsheet.Column
+	Field
+}
+
+// Reader allows to read fixed-width .txt files which columns are defined
+// by a schema rather than a header.
+type Reader struct {
+	loader.Source
+	ld     loader.Interface
+	schema Schema
+}
+
+func init() {
+	readers.Register("fixed", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// NewReader creates and initializes a new fixed-width spreadsheet reader.
+// The schema of a file is loaded from a companion .schema file which has
+// a line per column that consists of the column name, start and width
+// separated by spaces, e.g. "Credit Limit 40 12". Blank lines and lines
+// starting with # are skipped.
+func NewReader(ld loader.Interface) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".txt"), ld: ld}
+}
+
+// NewReaderWithSchema creates and initializes a new fixed-width spreadsheet
+// reader that reads every file according to the specified schema.
+func NewReaderWithSchema(ld loader.Interface, schema Schema) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".txt"), ld: ld, schema: schema}
+}
+
+// Raw opens the .txt file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".txt")
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	schema := rd.schema
+	if schema == nil {
+		var err error
+		if schema, err = rd.loadSchema(ctx, name); err != nil {
+			confirm <- err
+			return
+		}
+	}
+	columns, err := bind(schema)
+	if err != nil {
+		confirm <- err
+		return
+	}
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".txt")
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer f.Close()
+	confirm <- nil
+
+	r := bufio.NewReader(f)
+	line := 0
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			record, err := r.ReadString('\n')
+			line++
+			if err != nil && err != io.EOF {
+				log.Println("[FIXED]", err)
+				rows <- spreadsheet.InvalidRow(line, record)
+				return
+			}
+			if strings.TrimSpace(record) != "" {
+				rows <- readRow(strings.TrimRight(record, "\r\n"), columns)
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}
+}
+
+// loadSchema loads the schema of the file with the specified name.
+func (rd Reader) loadSchema(ctx context.Context, name string) (Schema, error) {
+	f, err := loader.LoadContext(ctx, rd.ld, name+".schema")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSchema(f)
+}
+
+// ParseSchema parses schema in the format of .schema files.
+func ParseSchema(r io.Reader) (Schema, error) {
+	var schema Schema
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.Fields(text)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("Invalid schema line %d", line)
+		}
+		start, err := strconv.Atoi(parts[len(parts)-2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid start in schema line %d", line)
+		}
+		width, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid width in schema line %d", line)
+		}
+		schema = append(schema, Field{
+			Column: strings.Join(parts[:len(parts)-2], " "),
+			Start:  start,
+			Width:  width,
+		})
+	}
+	return schema, s.Err()
+}
+
+// bind binds fields of the schema to known columns.
+func bind(schema Schema) ([]column, error) {
+	columns := make([]column, 0, len(schema))
+	for _, f := range schema {
+		col, ok := spreadsheet.FindColumn(f.Column)
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %s in schema", f.Column)
+		}
+		if f.Start < 0 || f.Width <= 0 {
+			return nil, fmt.Errorf("Invalid position of column %s in schema", f.Column)
+		}
+		columns = append(columns, column{Column: col, Field: f})
+	}
+	return columns, nil
+}
+
+// readRow carves values of the columns out of the record. Columns that
+// are beyond the end of the record are left blank.
+func readRow(record string, columns []column) spreadsheet.Row {
+	row := spreadsheet.Row{}
+	runes := []rune(record)
+	for _, col := range columns {
+		if col.Start >= len(runes) {
+			continue
+		}
+		end := col.Start + col.Width
+		if end > len(runes) {
+			end = len(runes)
+		}
+		col.Set(&row, strings.TrimSpace(string(runes[col.Start:end])))
+	}
+	row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, birthdayLayouts...)
+	return row
+}
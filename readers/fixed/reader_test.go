@@ -0,0 +1,118 @@
+package fixed
+
+import (
+	"errors"
+	"os"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(r *Reader, name string) ([]spreadsheet.Row, error) {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received, <-confirm
+}
+
+func TestReaderRead_SchemaFile_ExpectLinesCarved(t *testing.T) {
+	ld := loader.Files{
+		"name1.schema": "# name start width\n" +
+			"Name 0 16\n" +
+			"Postcode 16 8\n" +
+			"Credit Limit 24 8\n" +
+			"\n" +
+			"Birthday 32 8\n",
+		"name1.txt": "Stewart, Jamie  3123gg     5000019820201\n" +
+			"\n" +
+			"Leon, Mike      4532 AA  201092\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg", CreditLimit: "50000", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", Postcode: "4532 AA", CreditLimit: "201092"},
+	}
+
+	received, err := readAll(NewReader(ld), "name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_OutOfRangePositions_ExpectBlankOrTruncatedValues(t *testing.T) {
+	ld := loader.NewTest("Stewart, Jamie  Voorstraat\n")
+	schema := Schema{
+		{Column: "Name", Start: 0, Width: 16},
+		{Column: "Address", Start: 16, Width: 100},
+		{Column: "Phone", Start: 200, Width: 10},
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat"},
+	}
+
+	received, err := readAll(NewReaderWithSchema(ld, schema), "name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_NegativeStart_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTest("Stewart, Jamie\n")
+	schema := Schema{{Column: "Name", Start: -1, Width: 16}}
+
+	_, err := readAll(NewReaderWithSchema(ld, schema), "name1")
+
+	assert.EqualError(t, err, "Invalid position of column Name in schema")
+}
+
+func TestReaderRead_UnknownColumn_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTest("Stewart, Jamie\n")
+	schema := Schema{{Column: "Nickname", Start: 0, Width: 16}}
+
+	_, err := readAll(NewReaderWithSchema(ld, schema), "name1")
+
+	assert.EqualError(t, err, "Unknown column Nickname in schema")
+}
+
+func TestReaderRead_MissingSchema_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.Files{"name1.txt": "Stewart, Jamie\n"}
+
+	_, err := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestReaderRead_MissingFile_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+
+	_, err := readAll(NewReaderWithSchema(ld, Schema{{Column: "Name", Start: 0, Width: 1}}), "name1")
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+	assert.Equal(t, "name1.txt", ld.LoadName)
+}
+
+func TestParseSchema_InvalidLines_ErrorReturned(t *testing.T) {
+	testCases := []struct {
+		schema string
+		err    string
+	}{
+		{schema: "Name 0\n", err: "Invalid schema line 1"},
+		{schema: "Name 0 16\nPhone x 10\n", err: "Invalid start in schema line 2"},
+		{schema: "Name 0 wide\n", err: "Invalid width in schema line 1"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseSchema(strings.NewReader(tc.schema))
+		assert.EqualError(t, err, tc.err, "schema: %q", tc.schema)
+	}
+}
@@ -0,0 +1,118 @@
+package json
+
+import (
+	"io"
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+
+	json_enc "encoding/json"
+)
+
+var (
+	jsonParseError = "Unable to parse JSON"
+)
+
+// record defines keys of objects in a JSON file.
+type record struct {
+	Name        string      `json:"name"`
+	Address     string      `json:"address"`
+	Postcode    string      `json:"postcode"`
+	Phone       string      `json:"phone"`
+	CreditLimit creditLimit `json:"creditLimit"`
+	Birthday    string      `json:"birthday"`
+	Email       string      `json:"email"`
+	Company     string      `json:"company"`
+}
+
+// creditLimit accepts both a string and a number,
+// e.g. "50000" and 50000.
+type creditLimit string
+
+func (cl *creditLimit) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json_enc.Unmarshal(data, (*string)(cl))
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	var n json_enc.Number
+	if err := json_enc.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*cl = creditLimit(n)
+	return nil
+}
+
+// Reader allows to read .json files that contain an array of objects.
+// Missing keys leave the corresponding fields blank.
+type Reader struct {
+	loader.Source
+	ld loader.Interface
+}
+
+func init() {
+	readers.Register("json", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// NewReader creates and initializes a new .json spreadsheet reader.
+func NewReader(ld loader.Interface) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".json"), ld: ld}
+}
+
+// Raw opens the .json file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".json")
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".json")
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer f.Close()
+	confirm <- nil
+
+	// Objects are decoded one by one, so the whole file
+	// is never kept in memory.
+	dec := json_enc.NewDecoder(f)
+	if tok, err := dec.Token(); err != nil || tok != json_enc.Delim('[') {
+		log.Println("[JSON] Array expected in", name, err)
+		rows <- spreadsheet.Row{ErrorMessage: &jsonParseError}
+		return
+	}
+
+	for dec.More() {
+		select {
+		case <-stop:
+			return
+		default:
+			var rec record
+			if err := dec.Decode(&rec); err != nil {
+				log.Println("[JSON]", err)
+				rows <- spreadsheet.Row{ErrorMessage: &jsonParseError}
+				return
+			}
+			rows <- spreadsheet.Row{
+				Name:        rec.Name,
+				Address:     rec.Address,
+				Postcode:    rec.Postcode,
+				Phone:       rec.Phone,
+				CreditLimit: string(rec.CreditLimit),
+				Birthday:    rec.Birthday,
+				Email:       rec.Email,
+				Company:     rec.Company,
+			}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		log.Println("[JSON]", err)
+		rows <- spreadsheet.Row{ErrorMessage: &jsonParseError}
+	}
+}
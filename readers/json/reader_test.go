@@ -0,0 +1,138 @@
+package json
+
+import (
+	"errors"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/csv"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(r *Reader, name string) []spreadsheet.Row {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received
+}
+
+func TestReaderRead_LoadError_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	err := <-confirm
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+	assert.Equal(t, "name1.json", ld.LoadName)
+}
+
+func TestReaderRead_WellFormed_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(`[
+		{"name": "Stewart, Jamie", "address": "Voorstraat 47", "postcode": "3123gg",
+		 "phone": "020 7899381", "creditLimit": "50000", "birthday": "1982-02-01"},
+		{"name": "Leon, Mike", "email": "mike@example.com", "company": "Acme"}
+	]`)
+	expected := []spreadsheet.Row{
+		{
+			Name:        "Stewart, Jamie",
+			Address:     "Voorstraat 47",
+			Postcode:    "3123gg",
+			Phone:       "020 7899381",
+			CreditLimit: "50000",
+			Birthday:    "1982-02-01",
+		}, {
+			Name:    "Leon, Mike",
+			Email:   "mike@example.com",
+			Company: "Acme",
+		},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+	assert.True(t, ld.ReaderClosed)
+}
+
+func TestReaderRead_NumericCreditLimit_ExpectNumberAsIs(t *testing.T) {
+	ld := loader.NewTest(`[
+		{"name": "Stewart, Jamie", "creditLimit": 50000},
+		{"name": "Leon, Mike", "creditLimit": 4598.1},
+		{"name": "Gibson, Mal", "creditLimit": null}
+	]`)
+
+	received := readAll(NewReader(ld), "name1")
+
+	var limits []string
+	for _, row := range received {
+		assert.Nil(t, row.ErrorMessage)
+		limits = append(limits, row.CreditLimit)
+	}
+	assert.Equal(t, []string{"50000", "4598.1", ""}, limits)
+}
+
+func TestReaderRead_InvalidCreditLimit_ErrorRowSent(t *testing.T) {
+	ld := loader.NewTest(`[{"name": "Stewart, Jamie", "creditLimit": true}]`)
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Len(t, received, 1)
+	assert.NotNil(t, received[0].ErrorMessage)
+}
+
+func TestReaderRead_MalformedPartway_ErrorRowAfterContent(t *testing.T) {
+	ld := loader.NewTest(`[{"name": "Stewart, Jamie"}, {"name": "Leon`)
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, "Stewart, Jamie", received[0].Name)
+	assert.NotNil(t, received[1].ErrorMessage)
+	assert.Equal(t, "Unable to parse JSON", *received[1].ErrorMessage)
+}
+
+func TestReaderRead_NotArray_ErrorRowSent(t *testing.T) {
+	ld := loader.NewTest(`{"name": "Stewart, Jamie"}`)
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Len(t, received, 1)
+	assert.NotNil(t, received[0].ErrorMessage)
+}
+
+func TestReaderRead_EmptyArray_NoRows(t *testing.T) {
+	ld := loader.NewTest(`[]`)
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Empty(t, received)
+}
+
+func TestRoundTrip_BirthdayInCsvLayout_ExpectBirthdayNormalizedByCsvReader(t *testing.T) {
+	ld := loader.NewTest(`[
+		{"name": "Stewart, Jamie", "postcode": "3123gg", "birthday": "01/02/1982"},
+		{"name": "Leon, Mike", "birthday": "1975-01-15"}
+	]`)
+	reread := func(output []byte) spreadsheet.Reader {
+		return csv.NewReader(loader.NewTest(string(output)))
+	}
+	expected := []spreadsheet.Diff{
+		{Row: 1, Column: "Birthday", Before: "01/02/1982", After: "1982-02-01"},
+	}
+
+	diffs, err := spreadsheet.RoundTrip(NewReader(ld), "name1", reread)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, diffs)
+}
@@ -0,0 +1,124 @@
+package kv
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+	"strings"
+)
+
+// separator delimits a key from its value.
+const separator = ":"
+
+// birthdayLayouts lists formats in which birthdays are expected.
+var birthdayLayouts = []string{"02/01/2006", "20060102"}
+
+// Reader allows to read .kv files where every record is a block of
+// "key: value" lines and blocks are separated by blank lines.
+type Reader struct {
+	loader.Source
+	ld loader.Interface
+}
+
+func init() {
+	readers.Register("kv", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// NewReader creates and initializes a new .kv spreadsheet reader. Keys
+// are column names matched ignoring case, unknown keys are skipped. A block
+// with a line that isn't "key: value" is reported as an error row.
+func NewReader(ld loader.Interface) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".kv"), ld: ld}
+}
+
+// Raw opens the .kv file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".kv")
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".kv")
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer f.Close()
+	confirm <- nil
+
+	r := bufio.NewReader(f)
+	var b block
+	line := 0
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			record, err := r.ReadString('\n')
+			line++
+			if err != nil && err != io.EOF {
+				log.Println("[KV]", err)
+				rows <- spreadsheet.InvalidRow(line, record)
+				return
+			}
+			if strings.TrimSpace(record) != "" {
+				b.add(line, record)
+			} else if row, ok := b.flush(); ok {
+				rows <- row
+			}
+			if err == io.EOF {
+				if row, ok := b.flush(); ok {
+					rows <- row
+				}
+				return
+			}
+		}
+	}
+}
+
+// block accumulates lines of a record until a blank line.
+type block struct {
+	row  spreadsheet.Row
+	size int
+	// badLine is the number of the first malformed line
+	// of the block, zero if there is no such line.
+	badLine int
+	badRaw  string
+}
+
+// add reads the line with the specified number into the block.
+func (b *block) add(line int, record string) {
+	b.size++
+	key, value, ok := strings.Cut(record, separator)
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		if b.badLine == 0 {
+			b.badLine, b.badRaw = line, record
+		}
+		return
+	}
+	if col, ok := spreadsheet.FindColumn(key); ok {
+		col.Set(&b.row, strings.TrimSpace(value))
+	}
+}
+
+// flush returns the row of the accumulated lines and resets the block.
+// A malformed block gives an error row. If there are no lines, false
+// is returned.
+func (b *block) flush() (spreadsheet.Row, bool) {
+	defer func() { *b = block{} }()
+	if b.size == 0 {
+		return spreadsheet.Row{}, false
+	}
+	if b.badLine != 0 {
+		return spreadsheet.InvalidRow(b.badLine, b.badRaw), true
+	}
+	row := b.row
+	row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, birthdayLayouts...)
+	return row, true
+}
@@ -0,0 +1,92 @@
+package kv
+
+import (
+	"errors"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(r *Reader, name string) []spreadsheet.Row {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received
+}
+
+func TestReaderRead_LoadError_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	err := <-confirm
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+}
+
+func TestReaderRead_LoaderLoad_ExpectCorrectArgs(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("doesn't matter"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	<-confirm
+
+	assert.Equal(t, "name1.kv", ld.LoadName)
+}
+
+func TestReaderRead_ValidAndMalformedBlocks_ExpectRowPerBlock(t *testing.T) {
+	ld := loader.NewTest(
+		"Name: Stewart, Jamie\n" +
+			"address: Voorstraat 47\n" +
+			"POSTCODE: 3123gg\n" +
+			"Birthday: 01/02/1982\n" +
+			"Hobby: chess\n" +
+			"\n" +
+			"Name: Leon, Mike\n" +
+			"Credit limit\n" +
+			"\n" +
+			"\n" +
+			"Name: Kling, Jeramie\r\n" +
+			"Credit Limit: 4000\r\n")
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47", Postcode: "3123gg", Birthday: "1982-02-01"},
+		spreadsheet.InvalidRow(8, "Credit limit"),
+		{Name: "Kling, Jeramie", CreditLimit: "4000"},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_ValueWithSeparator_ExpectValueKept(t *testing.T) {
+	ld := loader.NewTest("Email: jamie:work@example.com\n")
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, []spreadsheet.Row{{Email: "jamie:work@example.com"}}, received)
+}
+
+func TestReaderRead_ReadError_ExpectErrorOnRows(t *testing.T) {
+	ld := loader.NewTestReadError(errors.New("wrong content"))
+
+	received := readAll(NewReader(ld), "name1")
+
+	if assert.Len(t, received, 1) {
+		assert.NotNil(t, received[0].ErrorMessage)
+	}
+}
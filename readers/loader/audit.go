@@ -0,0 +1,49 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// auditLoader reports every load of the inner loader to a sink.
+type auditLoader struct {
+	inner Interface
+	sink  func(name string, err error, at time.Time)
+}
+
+// NewAudit creates loader that loads objects by means of the inner
+// loader and calls sink after each load with the requested name, the
+// error of the load and the time it happened. Neither the returned
+// reader nor the error is altered.
+func NewAudit(inner Interface, sink func(name string, err error, at time.Time)) Interface {
+	return &auditLoader{inner: inner, sink: sink}
+}
+
+func (ld auditLoader) Load(name string) (io.ReadCloser, error) {
+	r, err := ld.inner.Load(name)
+	ld.sink(name, err, time.Now())
+	return r, err
+}
+
+func (ld auditLoader) LoadContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := LoadContext(ctx, ld.inner, name)
+	ld.sink(name, err, time.Now())
+	return r, err
+}
+
+// Stat passes through to the inner loader so that auditing doesn't
+// hide modification times. Stats aren't reported to the sink.
+func (ld auditLoader) Stat(name string) (os.FileInfo, error) {
+	if st, ok := ld.inner.(Stater); ok {
+		return st.Stat(name)
+	}
+	return nil, os.ErrNotExist
+}
+
+// Probe passes through to the inner loader. Probes aren't
+// reported to the sink.
+func (ld auditLoader) Probe() error {
+	return Probe(ld.inner)
+}
@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditEvent struct {
+	name string
+	err  error
+	at   time.Time
+}
+
+func TestAuditLoad_SuccessAndFailure_EventsRecordedInOrder(t *testing.T) {
+	var events []auditEvent
+	sink := func(name string, err error, at time.Time) {
+		events = append(events, auditEvent{name, err, at})
+	}
+	loadErr := errors.New("file is somewhere, but not here")
+	before := time.Now()
+
+	r, err := NewAudit(NewTest("content"), sink).Load("name1.csv")
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "content", string(content))
+
+	_, err = NewAudit(NewTestLoadError(loadErr), sink).Load("name2.csv")
+	assert.Equal(t, loadErr, err)
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "name1.csv", events[0].name)
+		assert.NoError(t, events[0].err)
+		assert.Equal(t, "name2.csv", events[1].name)
+		assert.Equal(t, loadErr, events[1].err)
+		assert.False(t, events[0].at.Before(before))
+		assert.False(t, events[1].at.Before(events[0].at))
+	}
+}
+
+func TestAuditLoadContext_Cancelled_ErrorRecorded(t *testing.T) {
+	var events []auditEvent
+	sink := func(name string, err error, at time.Time) {
+		events = append(events, auditEvent{name, err, at})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	_, err := LoadContext(ctx, NewAudit(NewFS(dir), sink), "a.csv")
+	assert.Equal(t, context.Canceled, err)
+
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "a.csv", events[0].name)
+		assert.Equal(t, context.Canceled, events[0].err)
+	}
+}
+
+func TestAuditStat_InnerStater_InfoReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	info, err := NewAudit(NewFS(dir), func(string, error, time.Time) {}).(Stater).Stat("a.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.csv", info.Name())
+}
+
+func TestAuditStat_InnerWithoutStat_ErrNotExistReturned(t *testing.T) {
+	_, err := NewAudit(plainLoader{}, func(string, error, time.Time) {}).(Stater).Stat("a.csv")
+	assert.Equal(t, os.ErrNotExist, err)
+}
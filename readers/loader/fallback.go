@@ -0,0 +1,72 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// fallbackLoader loads objects from the secondary loader
+// if the primary one fails.
+type fallbackLoader struct {
+	primary   Interface
+	secondary Interface
+}
+
+// NewFallback creates loader that loads objects by means of the primary
+// loader and falls back to the secondary one if the primary fails, e.g.
+// because its storage is inaccessible. Missing objects reported with
+// os.ErrNotExist by the primary loader don't cause the fallback since
+// the objects don't exist.
+func NewFallback(primary, secondary Interface) Interface {
+	return &fallbackLoader{primary: primary, secondary: secondary}
+}
+
+func (ld fallbackLoader) Load(name string) (io.ReadCloser, error) {
+	return ld.LoadContext(context.Background(), name)
+}
+
+func (ld fallbackLoader) LoadContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := LoadContext(ctx, ld.primary, name)
+	if err == nil || errors.Is(err, os.ErrNotExist) || ctx.Err() != nil {
+		return r, err
+	}
+	return LoadContext(ctx, ld.secondary, name)
+}
+
+// Probe succeeds if storage of either loader is reachable.
+func (ld fallbackLoader) Probe() error {
+	err := Probe(ld.primary)
+	if err == nil {
+		return nil
+	}
+	if serr := Probe(ld.secondary); serr != nil {
+		return errors.Join(err, serr)
+	}
+	return nil
+}
+
+// Stat describes the object by means of the loader that would load it,
+// i.e. the secondary loader is used only if the primary one fails for
+// other reasons than absence of the object.
+func (ld fallbackLoader) Stat(name string) (os.FileInfo, error) {
+	info, err := stat(ld.primary, name)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return info, err
+	}
+	return stat(ld.secondary, name)
+}
+
+// stat returns metadata of the object with the specified name if the
+// loader is a Stater. Otherwise, os.ErrNotExist is returned as long as
+// storage of the loader is reachable.
+func stat(ld Interface, name string) (os.FileInfo, error) {
+	if st, ok := ld.(Stater); ok {
+		return st.Stat(name)
+	}
+	if err := Probe(ld); err != nil {
+		return nil, err
+	}
+	return nil, os.ErrNotExist
+}
@@ -0,0 +1,129 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackLoad_PrimarySucceeded_PrimaryContentReturned(t *testing.T) {
+	primary, secondary := NewTest("primary"), NewTest("secondary")
+
+	r, err := NewFallback(primary, secondary).Load("a.csv")
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "primary", string(content))
+	assert.Empty(t, secondary.LoadName)
+}
+
+func TestFallbackLoad_PrimaryDown_SecondaryContentReturned(t *testing.T) {
+	primary := NewTestLoadError(errors.New("connection refused"))
+	secondary := NewTest("secondary")
+
+	r, err := NewFallback(primary, secondary).Load("a.csv")
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "secondary", string(content))
+	assert.Equal(t, "a.csv", primary.LoadName)
+	assert.Equal(t, "a.csv", secondary.LoadName)
+}
+
+func TestFallbackLoad_PrimaryNotExist_NoFallback(t *testing.T) {
+	for _, notExist := range []error{os.ErrNotExist, fmt.Errorf("a.csv: %w", os.ErrNotExist)} {
+		secondary := NewTest("secondary")
+
+		_, err := NewFallback(NewTestLoadError(notExist), secondary).Load("a.csv")
+
+		assert.Equal(t, notExist, err)
+		assert.Empty(t, secondary.LoadName)
+	}
+}
+
+func TestFallbackLoadContext_Cancelled_NoFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	secondary := NewTest("secondary")
+
+	_, err := LoadContext(ctx, NewFallback(NewFS(dir), secondary), "a.csv")
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, secondary.LoadName)
+}
+
+func TestFallbackProbe_PrimaryMissing_SecondaryProbed(t *testing.T) {
+	missing := NewFS(filepath.Join(os.TempDir(), "registry-sample-missing"))
+
+	assert.NoError(t, Probe(NewFallback(missing, NewFS(os.TempDir()))))
+	assert.Error(t, Probe(NewFallback(missing, missing)))
+}
+
+func TestFallbackStat_PrimaryStated_PrimaryModTimeReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2017, 10, 8, 16, 25, 37, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.csv"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	secondary := NewTest("secondary")
+	secondary.StatErr = errors.New("secondary isn't expected to stat")
+
+	modTime, ok := ModTime(NewFallback(NewFS(dir), secondary), "a.csv")
+
+	assert.True(t, ok)
+	assert.True(t, mtime.Equal(modTime))
+}
+
+func TestFallbackStat_PrimaryDown_SecondaryModTimeReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2017, 10, 8, 16, 25, 37, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.csv"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	primary := NewTest("primary")
+	primary.StatErr = errors.New("connection refused")
+
+	modTime, ok := ModTime(NewFallback(primary, NewFS(dir)), "a.csv")
+
+	assert.True(t, ok)
+	assert.True(t, mtime.Equal(modTime))
+}
+
+func TestFallbackStat_PrimaryNotExist_NoFallback(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	_, err := NewFallback(NewTest("primary"), NewFS(dir)).(Stater).Stat("a.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+// downLoader is a Prober that isn't a Stater with inaccessible storage.
+type downLoader struct {
+	plainLoader
+}
+
+func (ld downLoader) Probe() error {
+	return errors.New("connection refused")
+}
+
+func TestFallbackStat_PrimaryNotStaterAndDown_SecondaryModTimeReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	primary := downLoader{plainLoader{ld: NewTest("primary")}}
+
+	_, ok := ModTime(NewFallback(primary, NewFS(dir)), "a.csv")
+
+	assert.True(t, ok)
+}
@@ -0,0 +1,60 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpLoader implements loader abstraction over HTTP server.
+type httpLoader struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTP creates loader that fetches objects from the specified base
+// URL with GET requests. If client is nil, http.DefaultClient is used.
+func NewHTTP(baseURL string, client *http.Client) Interface {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpLoader{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+func (ld httpLoader) Load(name string) (io.ReadCloser, error) {
+	return ld.LoadContext(context.Background(), name)
+}
+
+func (ld httpLoader) LoadContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, ld.baseURL+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ld.client.Do(req.WithContext(ctx))
+	if err != nil {
+		// prefer context error since it's what the caller asked for
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unexpected status %s loading %s", resp.Status, name)
+	}
+}
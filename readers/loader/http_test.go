@@ -0,0 +1,86 @@
+package loader
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPLoad_StatusOK_BodyReturned(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	r, err := NewHTTP(srv.URL+"/", nil).Load("name1.csv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+	b, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "/name1.csv", path)
+	assert.Equal(t, "content", string(b))
+}
+
+func TestHTTPLoad_StatusNotFound_ErrNotExistReturned(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewHTTP(srv.URL, nil).Load("name1.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestHTTPLoad_StatusError_ErrorReturned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := NewHTTP(srv.URL, nil).Load("name1.csv")
+
+	assert.EqualError(t, err, "Unexpected status 502 Bad Gateway loading name1.csv")
+}
+
+func TestHTTPLoadContext_Cancelled_ContextErrorReturned(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	ld := NewHTTP(srv.URL, nil).(ContextLoader)
+	_, err := ld.LoadContext(ctx, "name1.csv")
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestHTTPLoadContext_DeadlineExceeded_ContextErrorReturned(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ld := NewHTTP(srv.URL, nil).(ContextLoader)
+	_, err := ld.LoadContext(ctx, "name1.csv")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -2,9 +2,17 @@ package loader
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Interface of loader abstracts persistent storage for readers.
@@ -14,9 +22,133 @@ type Interface interface {
 	Load(name string) (io.ReadCloser, error)
 }
 
+// ContextLoader is an optional interface implemented by loaders that
+// are able to abort loading when the specified context is done.
+type ContextLoader interface {
+	// LoadContext acts as Load but respects cancellation of ctx.
+	LoadContext(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// LoadContext loads the object with the specified name by means of
+// the specified loader. If the loader is a ContextLoader, ctx is
+// passed to it, otherwise a plain Load is used.
+func LoadContext(ctx context.Context, ld Interface, name string) (io.ReadCloser, error) {
+	if cld, ok := ld.(ContextLoader); ok {
+		return cld.LoadContext(ctx, name)
+	}
+	return ld.Load(name)
+}
+
+// StopContext returns a context that is cancelled once the specified
+// stop channel is closed. It allows readers to bridge their stop
+// channel to a context. The returned cancel function must be called
+// to release resources.
+func StopContext(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Stater is an optional interface implemented by loaders that are able
+// to provide metadata of objects without loading them.
+type Stater interface {
+	// Stat returns metadata of the object with the specified name.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// ModTime returns modification time of the object with the specified
+// name if the loader is a Stater. If the loader can't stat or the time
+// is unknown, false is returned.
+func ModTime(ld Interface, name string) (time.Time, bool) {
+	st, ok := ld.(Stater)
+	if !ok {
+		return time.Time{}, false
+	}
+	info, err := st.Stat(name)
+	if err != nil || info.ModTime().IsZero() {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// Lister is an optional interface implemented by loaders that are able
+// to enumerate objects in their storage. Loaders that can't list
+// simply don't implement it.
+type Lister interface {
+	// List returns names of all objects available for Load.
+	List() ([]string, error)
+}
+
+// ListExt returns names of objects available in the specified
+// Lister that have the specified extension, e.g. ".csv".
+func ListExt(l Lister, ext string) ([]string, error) {
+	names, err := l.List()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, name := range names {
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// Prober is an optional interface implemented by loaders that are able
+// to check whether their storage is reachable without loading anything.
+type Prober interface {
+	// Probe returns an error if storage is inaccessible.
+	Probe() error
+}
+
+// Probe checks whether storage of the specified loader is reachable if
+// the loader is a Prober. Otherwise, storage is assumed to be reachable.
+func Probe(ld Interface) error {
+	if p, ok := ld.(Prober); ok {
+		return p.Probe()
+	}
+	return nil
+}
+
+// Source binds a loader to files with a single extension. Readers
+// embed it to forward Probe and ModTime of their files to the loader.
+type Source struct {
+	ld  Interface
+	ext string
+}
+
+// NewSource creates a Source of files with extension ext, e.g. ".csv",
+// available through the specified loader.
+func NewSource(ld Interface, ext string) Source {
+	return Source{ld: ld, ext: ext}
+}
+
+// ModTime returns modification time of the file with the specified
+// name and the source's extension. See ModTime function for details.
+func (s Source) ModTime(name string) (time.Time, bool) {
+	return ModTime(s.ld, name+s.ext)
+}
+
+// Probe checks whether storage of the loader is reachable.
+func (s Source) Probe() error {
+	return Probe(s.ld)
+}
+
+// ErrTooLarge is returned by readers of loaders with limited size
+// once the limit is exceeded.
+var ErrTooLarge = errors.New("File exceeds size limit")
+
 // fsLoader implements loader abstraction over file system.
 type fsLoader struct {
-	dataDir string
+	dataDir  string
+	maxBytes int64
 }
 
 // NewFS creates loader that uses file system as a storage.
@@ -24,25 +156,149 @@ func NewFS(dataDir string) Interface {
 	return &fsLoader{dataDir: dataDir}
 }
 
+// NewFSWithLimit creates loader that uses file system as a storage and
+// doesn't allow to read more than maxBytes from a file. Once the limit
+// is exceeded, the reader returns ErrTooLarge.
+func NewFSWithLimit(dataDir string, maxBytes int64) Interface {
+	return &fsLoader{dataDir: dataDir, maxBytes: maxBytes}
+}
+
 func (ld fsLoader) Load(name string) (io.ReadCloser, error) {
+	return ld.LoadContext(context.Background(), name)
+}
+
+func (ld fsLoader) LoadContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	realName, err := ld.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(realName)
+	if err != nil || ld.maxBytes <= 0 {
+		return f, err
+	}
+	return &limitedReader{ReadCloser: f, left: ld.maxBytes}, nil
+}
+
+// limitedReader reads from the underlying reader until the limit is
+// exceeded. Unlike io.LimitReader, it fails rather than truncates.
+type limitedReader struct {
+	io.ReadCloser
+	left int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.left < 0 {
+		return 0, ErrTooLarge
+	}
+	// read one byte more to know whether the limit is exceeded.
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n + int(r.left), ErrTooLarge
+	}
+	return n, err
+}
+
+func (ld fsLoader) Stat(name string) (os.FileInfo, error) {
+	realName, err := ld.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(realName)
+}
+
+// Probe makes sure that the data directory exists.
+func (ld fsLoader) Probe() error {
+	dataDir := filepath.Clean(ld.dataDir)
+	info, err := os.Stat(dataDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Data directory %s is not a directory", dataDir)
+	}
+	return nil
+}
+
+// resolve returns the real path of the file with the specified name.
+// os.ErrNotExist is returned if the file is outside of data directory.
+func (ld fsLoader) resolve(name string) (string, error) {
 	dataDir := filepath.Clean(ld.dataDir)
 	fileName := filepath.Join(dataDir, name)
 
 	// Make sure that the file is within data directory.
 	if filepath.Dir(fileName) != dataDir {
-		return nil, os.ErrNotExist
+		return "", os.ErrNotExist
+	}
+
+	// The file may be a symlink that points outside of data
+	// directory, so check its real location as well.
+	realDir, err := filepath.EvalSymlinks(dataDir)
+	if err != nil {
+		return "", notExistOr(err)
+	}
+	realName, err := filepath.EvalSymlinks(fileName)
+	if err != nil {
+		return "", notExistOr(err)
+	}
+	if rel, err := filepath.Rel(realDir, realName); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", os.ErrNotExist
 	}
-	return os.Open(fileName)
+	return realName, nil
+}
+
+// notExistOr returns os.ErrNotExist if err reports a missing
+// file, otherwise err is returned as is.
+func notExistOr(err error) error {
+	if os.IsNotExist(err) {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// List returns sorted names of regular files in the data directory.
+// Hidden files and subdirectories are excluded.
+func (ld fsLoader) List() ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Clean(ld.dataDir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names, nil
 }
 
 // Test provides a way to test usage of loader.
 type Test struct {
+	mu    sync.Mutex
 	buf   *bytes.Buffer
 	rdErr error
 	ldErr error
 
 	LoadName     string
 	ReaderClosed bool
+
+	// ListNames is returned by List.
+	ListNames []string
+	// ListErr is returned by List if set.
+	ListErr error
+
+	// StatInfo is returned by Stat.
+	StatInfo os.FileInfo
+	// StatErr is returned by Stat if set.
+	StatErr error
 }
 
 // NewTest creates stub for testing with loader.
@@ -50,6 +306,18 @@ func NewTest(content string) *Test {
 	return &Test{buf: bytes.NewBufferString(content)}
 }
 
+// Files provides a way to test usage of loader with several files.
+// It serves files from memory by their names.
+type Files map[string]string
+
+func (ld Files) Load(name string) (io.ReadCloser, error) {
+	content, ok := ld[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
 // NewTestLoadError creates stub for testing with loader that returns error on load.
 func NewTestLoadError(err error) *Test {
 	return &Test{ldErr: err}
@@ -60,6 +328,20 @@ func NewTestReadError(err error) *Test {
 	return &Test{rdErr: err}
 }
 
+// NewTestReadErrorAfter creates stub for testing with loader which reader
+// returns error after the specified content is read.
+func NewTestReadErrorAfter(content string, err error) *Test {
+	return &Test{buf: bytes.NewBufferString(content), rdErr: err}
+}
+
+// Append adds the specified content to the end of content to be read.
+// It is safe to call Append while the content is being read.
+func (ld *Test) Append(content string) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	ld.buf.WriteString(content)
+}
+
 func (ld *Test) Load(name string) (io.ReadCloser, error) {
 	ld.LoadName = name
 	if ld.ldErr != nil {
@@ -68,11 +350,33 @@ func (ld *Test) Load(name string) (io.ReadCloser, error) {
 	return testReader{ld: ld}, nil
 }
 
+func (ld *Test) List() ([]string, error) {
+	if ld.ListErr != nil {
+		return nil, ld.ListErr
+	}
+	return ld.ListNames, nil
+}
+
+func (ld *Test) Stat(name string) (os.FileInfo, error) {
+	if ld.StatErr != nil {
+		return nil, ld.StatErr
+	}
+	if ld.StatInfo == nil {
+		return nil, os.ErrNotExist
+	}
+	return ld.StatInfo, nil
+}
+
 type testReader struct {
 	ld *Test
 }
 
 func (r testReader) Read(p []byte) (n int, err error) {
+	r.ld.mu.Lock()
+	defer r.ld.mu.Unlock()
+	if r.ld.buf != nil && r.ld.buf.Len() > 0 {
+		return r.ld.buf.Read(p)
+	}
 	if r.ld.rdErr != nil {
 		return 0, r.ld.rdErr
 	}
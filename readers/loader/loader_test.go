@@ -0,0 +1,300 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestDir(t *testing.T, files ...string) string {
+	dir, err := ioutil.TempDir("", "loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFSList_FilesInDir_NamesReturned(t *testing.T) {
+	dir := makeTestDir(t, "b.mon", "a.csv")
+	defer os.RemoveAll(dir)
+
+	names, err := NewFS(dir).(Lister).List()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.csv", "b.mon"}, names)
+}
+
+func TestFSList_HiddenAndSubdirs_Excluded(t *testing.T) {
+	dir := makeTestDir(t, "a.csv", ".hidden.csv")
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub.csv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := NewFS(dir).(Lister).List()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.csv"}, names)
+}
+
+func TestFSList_MissingDir_ErrorReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	os.RemoveAll(dir)
+
+	_, err := NewFS(dir).(Lister).List()
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListExt_MixedNames_OnlyExtReturned(t *testing.T) {
+	ld := &Test{ListNames: []string{"a.csv", "b.mon", "c.CSV", "csv"}}
+
+	names, err := ListExt(ld, ".csv")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.csv", "c.CSV"}, names)
+}
+
+func TestListExt_ListError_ErrorReturned(t *testing.T) {
+	ld := &Test{ListErr: errors.New("can't list")}
+
+	_, err := ListExt(ld, ".csv")
+
+	assert.EqualError(t, err, "can't list")
+}
+
+func TestFSLoadContext_Cancelled_ContextErrorReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewFS(dir).(ContextLoader).LoadContext(ctx, "a.csv")
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestLoadContext_PlainLoader_LoadUsed(t *testing.T) {
+	ld := NewTest("content")
+
+	r, err := LoadContext(context.Background(), plainLoader{ld}, "name1")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+	assert.Equal(t, "name1", ld.LoadName)
+}
+
+func TestStopContext_StopClosed_ContextCancelled(t *testing.T) {
+	stop := make(chan struct{})
+	ctx, cancel := StopContext(stop)
+	defer cancel()
+
+	close(stop)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled")
+	}
+}
+
+// plainLoader hides all optional interfaces of the wrapped loader.
+type plainLoader struct {
+	ld Interface
+}
+
+func (ld plainLoader) Load(name string) (io.ReadCloser, error) {
+	return ld.ld.Load(name)
+}
+
+func TestFSLoad_RegularFile_ContentReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	r, err := NewFS(dir).Load("a.csv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+	b, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "a.csv", string(b))
+}
+
+func TestFSLoad_MissingFile_ErrNotExistReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewFS(dir).Load("a.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestFSLoad_PathOutsideDir_ErrNotExistReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewFS(dir).Load("../a.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestFSLoad_SymlinkOutsideDir_ErrNotExistReturned(t *testing.T) {
+	outside := makeTestDir(t, "secret.csv")
+	defer os.RemoveAll(outside)
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.Symlink(filepath.Join(outside, "secret.csv"), filepath.Join(dir, "a.csv")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+
+	_, err := NewFS(dir).Load("a.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestFSLoad_SymlinkInsideDir_ContentReturned(t *testing.T) {
+	dir := makeTestDir(t, "b.csv")
+	defer os.RemoveAll(dir)
+	if err := os.Symlink(filepath.Join(dir, "b.csv"), filepath.Join(dir, "a.csv")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+
+	r, err := NewFS(dir).Load("a.csv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+	b, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "b.csv", string(b))
+}
+
+func TestFSStat_RegularFile_ModTimeReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2017, 10, 8, 16, 25, 37, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.csv"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime, ok := ModTime(NewFS(dir), "a.csv")
+
+	assert.True(t, ok)
+	assert.True(t, mtime.Equal(modTime))
+}
+
+func TestSourceModTime_NameWithoutExt_ModTimeOfFileWithExtReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2017, 10, 8, 16, 25, 37, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.csv"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime, ok := NewSource(NewFS(dir), ".csv").ModTime("a")
+
+	assert.True(t, ok)
+	assert.True(t, mtime.Equal(modTime))
+}
+
+func TestSourceProbe_MissingDataDir_ErrorReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+
+	err := NewSource(NewFS(filepath.Join(dir, "missing")), ".csv").Probe()
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFSStat_PathOutsideDir_ErrNotExistReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewFS(dir).(Stater).Stat("../a.csv")
+
+	assert.Equal(t, os.ErrNotExist, err)
+}
+
+func TestModTime_LoaderWithoutStat_FalseReturned(t *testing.T) {
+	_, ok := ModTime(plainLoader{ld: NewTest("")}, "a.csv")
+	assert.False(t, ok)
+}
+
+func TestModTime_StatError_FalseReturned(t *testing.T) {
+	ld := NewTest("")
+	ld.StatErr = errors.New("oops")
+
+	_, ok := ModTime(ld, "a.csv")
+
+	assert.False(t, ok)
+}
+
+func TestFSWithLimit_FileUnderLimit_ContentReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	for _, limit := range []int64{5, 100} {
+		f, err := NewFSWithLimit(dir, limit).Load("a.csv")
+		assert.NoError(t, err)
+		content, err := ioutil.ReadAll(f)
+		f.Close()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "a.csv", string(content))
+	}
+}
+
+func TestFSWithLimit_FileOverLimit_ErrTooLargeReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	f, err := NewFSWithLimit(dir, 4).Load("a.csv")
+	assert.NoError(t, err)
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+
+	assert.Equal(t, ErrTooLarge, err)
+	assert.Equal(t, "a.cs", string(content))
+}
+
+func TestFSProbe_ExistingDir_NoError(t *testing.T) {
+	dir := makeTestDir(t)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, Probe(NewFS(dir)))
+}
+
+func TestFSProbe_MissingDir_ErrorReturned(t *testing.T) {
+	dir := makeTestDir(t)
+	os.RemoveAll(dir)
+
+	err := Probe(NewFS(dir))
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFSProbe_FileInsteadOfDir_ErrorReturned(t *testing.T) {
+	dir := makeTestDir(t, "a.csv")
+	defer os.RemoveAll(dir)
+
+	err := Probe(NewFS(filepath.Join(dir, "a.csv")))
+
+	assert.Error(t, err)
+}
+
+func TestProbe_NotProber_NoError(t *testing.T) {
+	assert.NoError(t, Probe(NewTestLoadError(errors.New("never loaded"))))
+}
@@ -0,0 +1,37 @@
+package loader
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// ErrStreamLoaded is returned by stream loaders once
+// the stream has been loaded already.
+var ErrStreamLoaded = errors.New("Stream is already loaded")
+
+// streamLoader implements loader abstraction over a single stream.
+type streamLoader struct {
+	mu     sync.Mutex
+	r      io.Reader
+	loaded bool
+}
+
+// NewStream creates loader that provides the specified stream, e.g.
+// os.Stdin, regardless of the requested name. The stream can be loaded
+// only once, later loads return ErrStreamLoaded. Closing the returned
+// reader doesn't close the stream.
+func NewStream(r io.Reader) Interface {
+	return &streamLoader{r: r}
+}
+
+func (ld *streamLoader) Load(name string) (io.ReadCloser, error) {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	if ld.loaded {
+		return nil, ErrStreamLoaded
+	}
+	ld.loaded = true
+	return ioutil.NopCloser(ld.r), nil
+}
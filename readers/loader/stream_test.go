@@ -0,0 +1,26 @@
+package loader
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLoad_FirstLoad_StreamReturned(t *testing.T) {
+	ld := NewStream(strings.NewReader("content"))
+
+	r, err := ld.Load("any.csv")
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestStreamLoad_SecondLoad_ErrStreamLoadedReturned(t *testing.T) {
+	ld := NewStream(strings.NewReader("content"))
+	ld.Load("any.csv")
+
+	_, err := ld.Load("any.csv")
+	assert.Equal(t, ErrStreamLoaded, err)
+}
@@ -0,0 +1,183 @@
+package loader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+)
+
+// tarGzEntry locates a file within the uncompressed tar stream.
+type tarGzEntry struct {
+	offset int64
+	info   os.FileInfo
+}
+
+// tarGzLoader implements loader abstraction over files of a tar.gz archive.
+type tarGzLoader struct {
+	mu      sync.RWMutex
+	f       *os.File
+	size    int64
+	entries map[string]tarGzEntry
+}
+
+// NewTarGz creates loader that provides regular files of the tar.gz
+// archive at the specified path by their paths within the archive, e.g.
+// "customers.csv" or "2020/customers.csv". The archive is indexed once
+// here, so it must not change afterwards. Contents aren't kept in memory:
+// every load decompresses the archive up to the requested file, which is
+// safe to do concurrently. Missing files are reported with os.ErrNotExist.
+// The returned loader is an io.Closer that closes the archive. Once it's
+// closed, loads fail with os.ErrClosed.
+func NewTarGz(path string) (Interface, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ld := &tarGzLoader{f: f, size: info.Size()}
+	if ld.entries, err = ld.index(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return ld, nil
+}
+
+// countingReader counts bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// index finds regular files of the archive.
+func (ld *tarGzLoader) index() (map[string]tarGzEntry, error) {
+	gz, err := gzip.NewReader(io.NewSectionReader(ld.f, 0, ld.size))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	// tar reader doesn't read ahead, so the number of bytes
+	// read after a header is where the file content begins.
+	cr := &countingReader{r: gz}
+	tr := tar.NewReader(cr)
+	entries := map[string]tarGzEntry{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		entries[path.Clean(hdr.Name)] = tarGzEntry{offset: cr.n, info: hdr.FileInfo()}
+	}
+}
+
+// entry returns the entry with the specified name
+// along with the archive file.
+func (ld *tarGzLoader) entry(name string) (tarGzEntry, *os.File, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	if ld.f == nil {
+		return tarGzEntry{}, nil, os.ErrClosed
+	}
+	e, ok := ld.entries[path.Clean(name)]
+	if !ok {
+		return tarGzEntry{}, nil, os.ErrNotExist
+	}
+	return e, ld.f, nil
+}
+
+// tarGzReader reads a file of the archive.
+type tarGzReader struct {
+	io.Reader
+	gz *gzip.Reader
+}
+
+func (r tarGzReader) Close() error {
+	return r.gz.Close()
+}
+
+func (ld *tarGzLoader) Load(name string) (io.ReadCloser, error) {
+	e, f, err := ld.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	// ReadAt of the file is safe for concurrent use, so every
+	// load decompresses the archive on its own.
+	gz, err := gzip.NewReader(io.NewSectionReader(f, 0, ld.size))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, gz, e.offset); err != nil {
+		gz.Close()
+		return nil, err
+	}
+	return tarGzReader{Reader: io.LimitReader(gz, e.info.Size()), gz: gz}, nil
+}
+
+// Stat returns metadata of the file with the specified name
+// as it's recorded in the archive.
+func (ld *tarGzLoader) Stat(name string) (os.FileInfo, error) {
+	e, _, err := ld.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info, nil
+}
+
+// List returns sorted paths of regular files in the archive.
+func (ld *tarGzLoader) List() ([]string, error) {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	if ld.f == nil {
+		return nil, os.ErrClosed
+	}
+	names := make([]string, 0, len(ld.entries))
+	for name := range ld.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Probe makes sure that the archive isn't closed.
+func (ld *tarGzLoader) Probe() error {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	if ld.f == nil {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+// Close closes the archive. Readers of files that are
+// loaded already fail once the archive is closed.
+func (ld *tarGzLoader) Close() error {
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	if ld.f == nil {
+		return os.ErrClosed
+	}
+	err := ld.f.Close()
+	ld.f = nil
+	return err
+}
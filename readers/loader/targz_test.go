@@ -0,0 +1,168 @@
+package loader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeTarGz writes a tar.gz archive of the specified files
+// along with a directory entry and returns its path.
+func makeTarGz(t *testing.T, files map[string]string) string {
+	archive := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		hdr := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func loadAll(ld Interface, name string) (string, error) {
+	r, err := ld.Load(name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	return string(content), err
+}
+
+var tarGzFiles = map[string]string{
+	"customers.csv":     "Name,Address\n\"Stewart, Jamie\",Voorstraat 47\n",
+	"dir/customers.mon": "Name            Postcode\nStewart, Jamie  3123gg\n",
+	"./other.csv":       "Name\n\"Leon, Mike\"\n",
+}
+
+func TestTarGzLoad_CsvEntry_ContentReturned(t *testing.T) {
+	ld, err := NewTarGz(makeTarGz(t, tarGzFiles))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ld.(io.Closer).Close()
+
+	for name, expected := range map[string]string{
+		"customers.csv":     tarGzFiles["customers.csv"],
+		"dir/customers.mon": tarGzFiles["dir/customers.mon"],
+		"other.csv":         tarGzFiles["./other.csv"],
+	} {
+		content, err := loadAll(ld, name)
+		assert.NoError(t, err, "name: %s", name)
+		assert.Equal(t, expected, content, "name: %s", name)
+	}
+}
+
+func TestTarGzLoad_MissingEntry_ErrNotExistReturned(t *testing.T) {
+	ld, err := NewTarGz(makeTarGz(t, tarGzFiles))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ld.(io.Closer).Close()
+
+	for _, name := range []string{"missing.csv", "dir", "customers.mon"} {
+		_, err := ld.Load(name)
+		assert.True(t, os.IsNotExist(err), "name: %s", name)
+	}
+}
+
+func TestTarGzLoad_ConcurrentLoads_SameContentReturned(t *testing.T) {
+	ld, err := NewTarGz(makeTarGz(t, tarGzFiles))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ld.(io.Closer).Close()
+
+	var wg sync.WaitGroup
+	results := make([]string, 16)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = loadAll(ld, "dir/customers.mon")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, content := range results {
+		assert.Equal(t, tarGzFiles["dir/customers.mon"], content)
+	}
+}
+
+func TestTarGzStatAndList_Entries_MetadataReturned(t *testing.T) {
+	ld, err := NewTarGz(makeTarGz(t, tarGzFiles))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ld.(io.Closer).Close()
+
+	modTime, ok := ModTime(ld, "customers.csv")
+	assert.True(t, ok)
+	assert.True(t, modTime.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	names, err := ld.(Lister).List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"customers.csv", "dir/customers.mon", "other.csv"}, names)
+}
+
+func TestTarGzLoad_Closed_ErrClosedReturned(t *testing.T) {
+	ld, err := NewTarGz(makeTarGz(t, tarGzFiles))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, ld.(io.Closer).Close())
+	_, err = ld.Load("customers.csv")
+
+	assert.Equal(t, os.ErrClosed, err)
+	assert.Equal(t, os.ErrClosed, Probe(ld))
+}
+
+func TestNewTarGz_NotArchive_ErrorReturned(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := ioutil.WriteFile(name, []byte("Name,Address\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewTarGz(name)
+
+	assert.Error(t, err)
+}
+
+func TestNewTarGz_MissingArchive_ErrNotExistReturned(t *testing.T) {
+	_, err := NewTarGz(filepath.Join(t.TempDir(), "bundle.tar.gz"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -2,10 +2,18 @@ package mon
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"io"
 	"log"
+	"math"
+	"os"
+	"regexp"
 	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
 	"registry-sample/readers/loader"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -14,11 +22,15 @@ import (
 
 var (
 	columnParseError = "Unable to parse columns"
-	rowReadError     = "Invalid row"
+	noColumnsError   = "No recognizable columns"
 )
 
+// widthHint matches an inline width annotation that may follow
+// a column name in the header, e.g. Name<17>.
+var widthHint = regexp.MustCompile(`<(\d+)>`)
+
 type column struct {
-	name     string
+	spreadsheet.Column
 	occupies int
 }
 
@@ -28,16 +40,161 @@ type layout map[int]column
 
 // Reader allows to read formatted monospace delimited .mon files.
 type Reader struct {
-	ld loader.Interface
+	loader.Source
+	ld       loader.Interface
+	marker   rune
+	aliases  map[string]string
+	phone    func(string) string
+	postcode func(string) string
+	credit   func(string) string
+	follow   time.Duration
+	sidecar  bool
+	strict   bool
+}
+
+// followInterval is how long a following Reader waits for new
+// content once the end of a file is reached.
+const followInterval = 500 * time.Millisecond
+
+// Option configures optional behaviour of Reader.
+type Option func(*Reader)
+
+// WithMarker makes Reader split rows on the specified marker rune
+// when it is present in both the header and a row. Columns of every
+// marker-delimited segment are then read relative to the segment start,
+// which allows variable-width segments. Rows that don't match the
+// header's markers are read position-based as usual.
+func WithMarker(marker rune) Option {
+	return func(rd *Reader) {
+		rd.marker = marker
+	}
+}
+
+// WithPhoneNormalizer makes Reader pass every non-empty phone through
+// the specified function, e.g. spreadsheet.NormalizePhone.
+func WithPhoneNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.phone = normalize
+	}
+}
+
+// WithPostcodeNormalizer makes Reader pass every non-empty postcode
+// through the specified function, e.g. spreadsheet.NormalizePostcode.
+func WithPostcodeNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.postcode = normalize
+	}
+}
+
+// WithCreditLimitNormalizer makes Reader pass every non-empty credit
+// limit through the specified function, e.g. spreadsheet.NormalizeCreditLimit.
+func WithCreditLimitNormalizer(normalize func(string) string) Option {
+	return func(rd *Reader) {
+		rd.credit = normalize
+	}
+}
+
+func init() {
+	readers.Register("mon", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// WithSidecarHeader makes Reader take the header from name+".hdr" file,
+// if there is one, when the first line of a .mon file has no known
+// columns. Such a line is read as a row then.
+func WithSidecarHeader() Option {
+	return func(rd *Reader) {
+		rd.sidecar = true
+	}
+}
+
+// WithStrictHeader makes Reader fail on a header without any known
+// columns, e.g. when the file isn't .mon at all. Instead of blank rows
+// a single error row telling there are no recognizable columns is read.
+// Titles count only if they are delimited by spaces or the marker, so
+// a CSV header like "Name,Address" isn't taken for .mon one. A sidecar
+// header, if enabled, is tried first.
+func WithStrictHeader() Option {
+	return func(rd *Reader) {
+		rd.strict = true
+	}
 }
 
 // NewReader creates and initializes a new .mon spreadsheet reader.
-func NewReader(ld loader.Interface) *Reader {
-	return &Reader{ld: ld}
+func NewReader(ld loader.Interface, opts ...Option) *Reader {
+	rd := &Reader{Source: loader.NewSource(ld, ".mon"), ld: ld}
+	for _, opt := range opts {
+		opt(rd)
+	}
+	return rd
 }
 
-func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+// NewReaderWithAliases creates and initializes a new .mon spreadsheet
+// reader that also recognizes columns by the specified aliases, e.g.
+// "Zip" for "Postcode". Keys are header titles and values are canonical
+// column names. Like other titles, aliases are searched case-sensitively.
+func NewReaderWithAliases(ld loader.Interface, aliases map[string]string, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.aliases = aliases
+	return rd
+}
+
+// NewReaderFollow creates and initializes a new .mon spreadsheet reader
+// that doesn't stop at the end of a file but keeps it open and reads rows
+// as they are appended, like tail -f. Reading lasts until it is stopped.
+func NewReaderFollow(ld loader.Interface, opts ...Option) *Reader {
+	rd := NewReader(ld, opts...)
+	rd.follow = followInterval
+	return rd
+}
+
+// Raw opens the .mon file with the specified name as is.
+func (rd Reader) Raw(name string) (io.ReadCloser, error) {
+	return rd.ld.Load(name + ".mon")
+}
+
+// LayoutColumn describes where a column is found in .mon file. Start
+// and Occupies are counted in runes.
+type LayoutColumn struct {
+	Column   string `json:"column"`
+	Start    int    `json:"start"`
+	Occupies int    `json:"occupies"`
+}
+
+// Layout returns columns detected in the header of the .mon file with
+// the specified name ordered by their start. Rows are not read.
+func (rd Reader) Layout(name string) (interface{}, error) {
 	f, err := rd.ld.Load(name + ".mon")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	lt := parseLayout(header, rd.aliases)
+	if len(lt) == 0 && rd.sidecar {
+		sidecar, err := rd.sidecarHeader(context.Background(), name)
+		if err != nil {
+			return nil, err
+		}
+		lt = parseLayout(sidecar, rd.aliases)
+	}
+
+	result := make([]LayoutColumn, 0, len(lt))
+	for start, col := range lt {
+		result = append(result, LayoutColumn{Column: col.Name, Start: start, Occupies: col.occupies})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result, nil
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".mon")
 	if err != nil {
 		confirm <- err
 		return
@@ -46,7 +203,7 @@ func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet
 	confirm <- nil
 
 	r := bufio.NewReader(f)
-	layout, err := readLayout(r)
+	header, err := r.ReadString('\n')
 	if err != nil {
 		if err != io.EOF {
 			// if we can't read layout, we can't read the entire file.
@@ -55,37 +212,175 @@ func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet
 		}
 		return
 	}
+	lt := parseLayout(header, rd.aliases)
+	first := ""
+	if len(lt) == 0 && rd.sidecar {
+		sidecar, err := rd.sidecarHeader(ctx, name)
+		if err != nil {
+			log.Println("[MON]", err)
+			rows <- spreadsheet.Row{ErrorMessage: &columnParseError}
+			return
+		}
+		if sidecar != "" {
+			// the first line isn't a header, so it's read again as a row.
+			first = header
+			header = sidecar
+			lt = parseLayout(header, rd.aliases)
+		}
+	}
+	if rd.strict && !recognizable(header, rd.marker, rd.aliases) {
+		log.Printf("[MON] %s: %s", name, noColumnsError)
+		rows <- spreadsheet.Row{ErrorMessage: &noColumnsError}
+		return
+	}
+
+	var segments []layout
+	if rd.marker != 0 && strings.ContainsRune(header, rd.marker) {
+		for _, seg := range strings.Split(header, string(rd.marker)) {
+			segments = append(segments, openEnded(parseLayout(seg, rd.aliases)))
+		}
+	}
 
+	line := 1
+	if first != "" {
+		line = 0
+	}
+	pending := ""
 	for {
 		select {
 		case <-stop:
 			return
 		default:
-			row, err := readRow(r, layout)
+			// the file reader is kept as is, since
+			// following it depends on reading past EOF.
+			var record string
+			var err error
+			if first != "" {
+				record, first = first, ""
+			} else {
+				record, err = r.ReadString('\n')
+			}
+			if err == io.EOF && rd.follow > 0 {
+				// the last line may be still being written,
+				// so keep it until the rest is appended.
+				pending += record
+				select {
+				case <-stop:
+					return
+				case <-time.After(rd.follow):
+				}
+				continue
+			}
+			record, pending = pending+record, ""
+			line++
 			if err == io.EOF {
 				return
 			}
 			if err != nil {
 				log.Println("[MON]", err)
-				rows <- spreadsheet.Row{ErrorMessage: &rowReadError}
+				rows <- spreadsheet.InvalidRow(line, record)
 				return
 			}
+			if strings.TrimSpace(record) == "" {
+				// blank lines don't carry any data.
+				continue
+			}
+			row := spreadsheet.Row{}
+			if parts := rd.split(record, len(segments)); parts != nil {
+				for i, part := range parts {
+					readRecord(part, segments[i], &row)
+				}
+			} else {
+				readRecord(record, lt, &row)
+			}
+			row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, "20060102")
+			if rd.phone != nil && row.Phone != "" {
+				row.Phone = rd.phone(row.Phone)
+			}
+			if rd.postcode != nil && row.Postcode != "" {
+				row.Postcode = rd.postcode(row.Postcode)
+			}
+			if rd.credit != nil && row.CreditLimit != "" {
+				row.CreditLimit = rd.credit(row.CreditLimit)
+			}
 			rows <- row
 		}
 	}
 }
 
-func readLayout(r *bufio.Reader) (layout, error) {
-	record, err := r.ReadString('\n')
+// sidecarHeader loads the header of the .mon file with the specified
+// name from name+".hdr" file. If there is no such file, an empty
+// header is returned.
+func (rd Reader) sidecarHeader(ctx context.Context, name string) (string, error) {
+	f, err := loader.LoadContext(ctx, rd.ld, name+".hdr")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	defer f.Close()
+	header, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return header, nil
+}
 
+// split splits the record by the marker if it contains exactly
+// the expected number of segments, otherwise nil is returned.
+func (rd Reader) split(record string, expected int) []string {
+	if expected == 0 {
+		return nil
+	}
+	parts := strings.Split(record, string(rd.marker))
+	if len(parts) != expected {
+		return nil
+	}
+	return parts
+}
+
+// title is a text in the header that denotes a known column.
+type title struct {
+	text string
+	col  spreadsheet.Column
+}
+
+// titles returns titles of known columns followed by the specified
+// aliases, so a column found by alias replaces one found by name.
+func titles(aliases map[string]string) []title {
+	var result []title
+	for _, col := range spreadsheet.Columns {
+		result = append(result, title{text: col.Name, col: col})
+	}
+	sources := make([]string, 0, len(aliases))
+	for source := range aliases {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		if col, ok := spreadsheet.FindColumn(aliases[source]); ok {
+			result = append(result, title{text: source, col: col})
+		}
+	}
+	return result
+}
+
+func parseLayout(record string, aliases map[string]string) layout {
+	record, hints := stripWidthHints(record)
 	lt := layout{}
+	hinted := map[int]int{}
+	// titleLens keeps rune lengths of found titles by their starts.
+	titleLens := map[int]int{}
 
 	// Column search is case-sensitive for now.
 	// Consider make it insensitive in a future.
-	findCol := func(name string) {
+	// Only the first occurrence of a title is found, so
+	// duplicated columns are ignored. Unlike the CSV reader,
+	// no warning is logged since the rest of the header isn't
+	// split to titles and duplicates are unknown.
+	for _, t := range titles(aliases) {
+		name := t.text
 		if idx := strings.Index(record, name); idx >= 0 {
 			// we must walk runes not bytes because space-separated
 			// content is tightly coupled to visual representation.
@@ -100,65 +395,147 @@ func readLayout(r *bufio.Reader) (layout, error) {
 				}
 			}
 			lt[start] = column{
-				name:     strings.ToLower(name),
+				Column:   t.col,
 				occupies: count,
 			}
+			titleLens[start] = utf8.RuneCountInString(name)
+			if width, ok := hints[idx+len(name)]; ok {
+				hinted[start] = width
+			}
+		}
+	}
+	// a title found inside another one, e.g. "Name" in "Full Name",
+	// isn't a column on its own.
+	for start := range lt {
+		for other, length := range titleLens {
+			if other < start && start < other+length {
+				delete(lt, start)
+				delete(hinted, start)
+				break
+			}
 		}
 	}
+	return applyWidthHints(lt, hinted)
+}
 
-	findCol("Name")
-	findCol("Address")
-	findCol("Postcode")
-	findCol("Phone")
-	findCol("Credit Limit")
-	findCol("Birthday")
-	return lt, nil
+// recognizable reports whether the header contains a known title
+// delimited by spaces, the marker, width hints or the line edges.
+func recognizable(header string, marker rune, aliases map[string]string) bool {
+	header, hints := stripWidthHints(header)
+	delimiter := func(r rune) bool { return unicode.IsSpace(r) || (marker != 0 && r == marker) }
+	for _, t := range titles(aliases) {
+		for off := 0; ; {
+			idx := strings.Index(header[off:], t.text)
+			if idx < 0 {
+				break
+			}
+			start, end := off+idx, off+idx+len(t.text)
+			before, _ := utf8.DecodeLastRuneInString(header[:start])
+			after, _ := utf8.DecodeRuneInString(header[end:])
+			_, hinted := hints[end]
+			if (start == 0 || delimiter(before)) && (end == len(header) || hinted || delimiter(after)) {
+				return true
+			}
+			off = end
+		}
+	}
+	return false
 }
 
-func readRow(r *bufio.Reader, lt layout) (spreadsheet.Row, error) {
-	row := spreadsheet.Row{}
+// stripWidthHints removes width annotations from the header. Returned map
+// contains widths by byte indices in the stripped header where they were.
+func stripWidthHints(record string) (string, map[int]int) {
+	hints := map[int]int{}
+	var stripped []byte
+	last := 0
+	for _, m := range widthHint.FindAllStringSubmatchIndex(record, -1) {
+		stripped = append(stripped, record[last:m[0]]...)
+		if width, err := strconv.Atoi(record[m[2]:m[3]]); err == nil && width > 0 {
+			hints[len(stripped)] = width
+		}
+		last = m[1]
+	}
+	if last == 0 {
+		return record, hints
+	}
+	stripped = append(stripped, record[last:]...)
+	return string(stripped), hints
+}
 
-	record, err := r.ReadString('\n')
-	if err != nil {
-		return row, err
+// applyWidthHints sets explicit widths of hinted columns. When a hint makes
+// a column wider than the header shows, the columns that follow it are
+// shifted to the right by the same number of runes.
+func applyWidthHints(lt layout, hinted map[int]int) layout {
+	if len(hinted) == 0 {
+		return lt
 	}
 
+	starts := make([]int, 0, len(lt))
+	for start := range lt {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	result := layout{}
+	shift := 0
+	for _, start := range starts {
+		col := lt[start]
+		natural := col.occupies
+		if width, ok := hinted[start]; ok {
+			col.occupies = width
+		}
+		result[start+shift] = col
+		if col.occupies > natural {
+			shift += col.occupies - natural
+		}
+	}
+	return result
+}
+
+// openEnded makes the last column of the layout occupy everything
+// up to the end of a record.
+func openEnded(lt layout) layout {
+	last := -1
+	for start := range lt {
+		if start > last {
+			last = start
+		}
+	}
+	if col, ok := lt[last]; ok {
+		col.occupies = math.MaxInt32
+		lt[last] = col
+	}
+	return lt
+}
+
+func readRecord(record string, lt layout, row *spreadsheet.Row) {
 	runeNum := 0
 	waitRuneNum := -1
 	colIdx := 0
-	colName := ""
+	var colSet func(*spreadsheet.Row, string)
 
-	for i := range record {
-		if runeNum > waitRuneNum {
-			// look for a column started at the current rune
-			if col, ok := lt[runeNum]; ok {
-				colIdx = i
-				colName = col.name
-				waitRuneNum = runeNum + col.occupies - 1
+	for i, r := range record {
+		// look for a column started at the current rune
+		if col, ok := lt[runeNum]; ok {
+			if colSet != nil {
+				// the current column is clamped at the start of the next
+				// one, so that adjacent columns never bleed into each other
+				// even if the width of the current one overruns.
+				colSet(row, strings.TrimSpace(record[colIdx:i]))
 			}
-		} else if runeNum == waitRuneNum {
+			colIdx = i
+			colSet = col.Set
+			waitRuneNum = runeNum + col.occupies - 1
+		}
+		if runeNum == waitRuneNum && colSet != nil {
 			// we've reached the rune where the current col ends
-			v := strings.TrimSpace(record[colIdx : i+1])
-			switch colName {
-			case "name":
-				row.Name = v
-			case "address":
-				row.Address = v
-			case "postcode":
-				row.Postcode = v
-			case "phone":
-				row.Phone = v
-			case "credit limit":
-				row.CreditLimit = v
-			case "birthday":
-				if t, err := time.Parse("20060102", v); err == nil {
-					row.Birthday = t.Format("2006-01-02")
-				} else {
-					row.Birthday = v
-				}
-			}
+			colSet(row, strings.TrimSpace(record[colIdx:i+utf8.RuneLen(r)]))
+			colSet = nil
 		}
 		runeNum++
 	}
-	return row, nil
+	if colSet != nil {
+		// the record is shorter than the column
+		colSet(row, strings.TrimSpace(record[colIdx:]))
+	}
 }
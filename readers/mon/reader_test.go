@@ -2,9 +2,16 @@ package mon
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/csv"
 	"registry-sample/readers/loader"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -162,3 +169,631 @@ func TestReaderRead_LoadOk_ExpectReaderClosed(t *testing.T) {
 
 	assert.True(t, ld.ReaderClosed)
 }
+
+func TestReaderRead_WithMarker_ExpectFieldsSplitByMarker(t *testing.T) {
+	ld := loader.NewTest(
+		"Name           Address|Postcode Phone       |Birthday\n" +
+			"Stewart, Jamie Voorstraat 47|3123gg   020 7899381|19820201\n" +
+			"Leon, Mike     Dorpsplein|4532 AA  030 2288986 |19671103\n" +
+			"Kling, Jeramie Bergweg 3423 ba  0156-210475  19680503\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{
+			Name:     "Stewart, Jamie",
+			Address:  "Voorstraat 47",
+			Postcode: "3123gg",
+			Phone:    "020 7899381",
+			Birthday: "1982-02-01",
+		}, {
+			Name:     "Leon, Mike",
+			Address:  "Dorpsplein",
+			Postcode: "4532 AA",
+			Phone:    "030 2288986",
+			Birthday: "1967-11-03",
+		}, {
+			Name:     "Kling, Jeramie",
+			Address:  "Bergweg",
+			Postcode: "3423 ba",
+			Phone:    "0156-210475",
+			Birthday: "1968-05-03",
+		},
+	}
+
+	r := NewReader(ld, WithMarker('|'))
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Len(t, received, 3)
+	assert.Contains(t, received, expected[0])
+	assert.Contains(t, received, expected[1])
+	assert.Contains(t, received, expected[2])
+}
+
+func TestReaderRead_WidthHintInHeader_ExpectExplicitWidthHonored(t *testing.T) {
+	ld := loader.NewTest(
+		"Name<16> Address<14>Postcode\n" +
+			"Stewart, Jamie  Voorstraat 47 3123gg\n" +
+			"Conceptión, JoeyDriehoog 3    2340 CC\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{
+			Name:     "Stewart, Jamie",
+			Address:  "Voorstraat 47",
+			Postcode: "3123gg",
+		}, {
+			Name:     "Conceptión, Joey",
+			Address:  "Driehoog 3",
+			Postcode: "2340 CC",
+		},
+	}
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Len(t, received, 2)
+	assert.Contains(t, received, expected[0])
+	assert.Contains(t, received, expected[1])
+}
+
+func TestParseLayout_WidthHint_ExpectAnnotationStripped(t *testing.T) {
+	lt := parseLayout("Name<16> Address<14>Postcode\n", nil)
+
+	assert.Equal(t, map[int]string{
+		0:  "Name/16",
+		16: "Address/14",
+		30: "Postcode/8",
+	}, describeLayout(lt))
+}
+
+func TestParseLayout_TitleInsideAnotherTitle_ExpectInnerTitleDropped(t *testing.T) {
+	lt := parseLayout("Company Name    Credit Limit\n", map[string]string{"Company Name": "Company"})
+
+	assert.Equal(t, map[int]string{
+		0:  "Company/16",
+		16: "Credit Limit/12",
+	}, describeLayout(lt))
+}
+
+// describeLayout represents columns of the layout as "Name/occupies"
+// because setters of columns can't be compared.
+func describeLayout(lt layout) map[int]string {
+	result := map[int]string{}
+	for start, col := range lt {
+		result[start] = fmt.Sprintf("%s/%d", col.Name, col.occupies)
+	}
+	return result
+}
+
+func TestParseLayout_SharedColumns_AllRecognized(t *testing.T) {
+	var header []string
+	for _, col := range spreadsheet.Columns {
+		header = append(header, col.Name)
+	}
+	lt := parseLayout(strings.Join(header, " ")+"\n", nil)
+
+	var starts []int
+	for start := range lt {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+	var names []string
+	for _, start := range starts {
+		names = append(names, lt[start].Name)
+	}
+	assert.Equal(t, header, names)
+}
+
+func TestReaderRead_ReadErrorInRow_ExpectLineAndPreviewInError(t *testing.T) {
+	ld := loader.NewTestReadErrorAfter(
+		"Name           Address\n"+
+			"Stewart, Jamie Voorstraat 47\n"+
+			"Leon, Mike     Dorpsplein 5A",
+		errors.New("connection lost"))
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	if assert.Len(t, received, 2) && assert.NotNil(t, received[1].ErrorMessage) {
+		assert.Equal(t, `Invalid row 3: "Leon, Mike     Dorps..."`, *received[1].ErrorMessage)
+	}
+}
+
+func TestReaderRead_WithPhoneNormalizer_ExpectPhoneNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name             Phone         \n" +
+			"Stewart, Jamie   020 7899381\n" +
+			"Nordberg, Taylor +1 709 880038\n" +
+			"Leon, Mike       n/a\n" +
+			"Kling, Jeramie\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld, WithPhoneNormalizer(spreadsheet.NormalizePhone))
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var phones []string
+	for row := range rows {
+		phones = append(phones, row.Phone)
+	}
+
+	assert.Equal(t, []string{"0207899381", "+1709880038", "n/a (invalid)", ""}, phones)
+}
+
+func TestReaderRead_WithPostcodeNormalizer_ExpectPostcodeNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name             Postcode \n" +
+			"Stewart, Jamie   3123gg\n" +
+			"Leon, Mike       4532 AA\n" +
+			"Gibson, Mal      91455\n" +
+			"Nordberg, Taylor 12-34\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld, WithPostcodeNormalizer(spreadsheet.NormalizePostcode))
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var postcodes []string
+	for row := range rows {
+		postcodes = append(postcodes, row.Postcode)
+	}
+
+	assert.Equal(t, []string{"3123 GG", "4532 AA", "91455", "12-34 (invalid)"}, postcodes)
+}
+
+func TestReaderRead_WithCreditLimitNormalizer_ExpectCreditLimitNormalized(t *testing.T) {
+	ld := loader.NewTest(
+		"Name             Credit Limit\n" +
+			"Stewart, Jamie   $1,234.5\n" +
+			"Leon, Mike       50000\n" +
+			"Nordberg, Taylor N/A\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+
+	r := NewReader(ld, WithCreditLimitNormalizer(spreadsheet.NormalizeCreditLimit))
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var limits []string
+	for row := range rows {
+		limits = append(limits, row.CreditLimit)
+	}
+
+	assert.Equal(t, []string{"1234.50", "50000.00", "N/A"}, limits)
+}
+
+func TestReaderRead_EmailAndCompanyColumns_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(
+		"Name           Address       Postcode Phone       Credit Limit Birthday Email             Company    Unknown\n" +
+			"Stewart, Jamie Voorstraat 47 3123gg   020 7899381        50000 19820201 jamie@example.com Acme, Inc. x      \n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := spreadsheet.Row{
+		Name:        "Stewart, Jamie",
+		Address:     "Voorstraat 47",
+		Postcode:    "3123gg",
+		Phone:       "020 7899381",
+		CreditLimit: "50000",
+		Birthday:    "1982-02-01",
+		Email:       "jamie@example.com",
+		Company:     "Acme, Inc.",
+	}
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, []spreadsheet.Row{expected}, received)
+}
+
+func TestReaderRead_BlankLines_ExpectNoEmptyRows(t *testing.T) {
+	ld := loader.NewTest(
+		"Name           Address      \n" +
+			"\n" +
+			"Stewart, Jamie Voorstraat 47\n" +
+			"   \t  \n" +
+			"               Dorpsplein 5A\n" +
+			"\n" +
+			"  \n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+		{Address: "Dorpsplein 5A"},
+	}
+
+	r := NewReader(ld)
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_WithAliases_ExpectAliasedColumnsPopulated(t *testing.T) {
+	ld := loader.NewTest(
+		"Full Name      Zip    DOB     \n" +
+			"Stewart, Jamie 3123gg 19820201\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg", Birthday: "1982-02-01"},
+	}
+
+	r := NewReaderWithAliases(ld, map[string]string{
+		"Full Name": "name",
+		"Zip":       "Postcode",
+		"DOB":       "BIRTHDAY",
+	})
+	go func() {
+		defer close(rows)
+		r.Read("name1", confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_Follow_ExpectAppendedRowsBeforeStop(t *testing.T) {
+	ld := loader.NewTest(
+		"Name           Address      \n" +
+			"Stewart, Jamie Voorstraat 47\n")
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	r := NewReaderFollow(ld)
+	r.follow = time.Millisecond
+	go func() {
+		defer close(done)
+		r.Read("name1", confirm, rows, stop)
+	}()
+
+	assert.Equal(t, "Stewart, Jamie", (<-rows).Name)
+
+	// the line is appended in two parts to make sure
+	// that a partially written line isn't read.
+	ld.Append("Leon, Mike     ")
+	time.Sleep(10 * time.Millisecond)
+	ld.Append("Dorpsplein 5A\n")
+	assert.Equal(t, spreadsheet.Row{Name: "Leon, Mike", Address: "Dorpsplein 5A"}, <-rows)
+
+	select {
+	case <-done:
+		t.Fatal("Read returned before stop")
+	default:
+	}
+	close(stop)
+	<-done
+	assert.True(t, ld.ReaderClosed)
+}
+
+func TestReaderLayout_SampleHeader_ExpectStartsAndWidths(t *testing.T) {
+	ld := loader.NewTest(
+		"Name            Uknown1            Postcode Uknown2      Credit Limit Birthday\n" +
+			"Stewart, Jamie  Voorstraat 47        3123gg 020 7899381         50000 19820201\n")
+	expected := []LayoutColumn{
+		{Column: "Name", Start: 0, Occupies: 16},
+		{Column: "Postcode", Start: 35, Occupies: 9},
+		{Column: "Credit Limit", Start: 57, Occupies: 13},
+		{Column: "Birthday", Start: 70, Occupies: 8},
+	}
+
+	lt, err := NewReader(ld).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, lt)
+	assert.Equal(t, "name1.mon", ld.LoadName)
+}
+
+func TestReaderLayout_WidthHint_ExpectHintedWidth(t *testing.T) {
+	ld := loader.NewTest("Name<20> Postcode\n")
+	expected := []LayoutColumn{
+		{Column: "Name", Start: 0, Occupies: 20},
+		{Column: "Postcode", Start: 20, Occupies: 8},
+	}
+
+	lt, err := NewReader(ld).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, lt)
+}
+
+func TestReaderLayout_LoadError_ExpectError(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+
+	_, err := NewReader(ld).Layout("name1")
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+}
+
+func readAll(r *Reader, name string) []spreadsheet.Row {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received
+}
+
+func TestReaderRead_SidecarHeader_ExpectAllLinesReadAsRows(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name            Postcode Birthday\n",
+		"name1.mon": "Stewart, Jamie  3123gg   19820201\n" +
+			"Leon, Mike      4532 AA  19750115\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", Postcode: "4532 AA", Birthday: "1975-01-15"},
+	}
+
+	received := readAll(NewReader(ld, WithSidecarHeader()), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+// sidecarLoader serves the sidecar header from memory
+// and everything else from the embedded test loader.
+type sidecarLoader struct {
+	*loader.Test
+	header string
+}
+
+func (ld sidecarLoader) Load(name string) (io.ReadCloser, error) {
+	if strings.HasSuffix(name, ".hdr") {
+		return ioutil.NopCloser(strings.NewReader(ld.header)), nil
+	}
+	return ld.Test.Load(name)
+}
+
+func TestReaderRead_FollowWithSidecarHeader_ExpectAppendedRowsBeforeStop(t *testing.T) {
+	ld := sidecarLoader{
+		Test:   loader.NewTest("Stewart, Jamie Voorstraat 47\n"),
+		header: "Name           Address      \n",
+	}
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	r := NewReaderFollow(ld, WithSidecarHeader())
+	r.follow = time.Millisecond
+	go func() {
+		defer close(done)
+		r.Read("name1", confirm, rows, stop)
+	}()
+
+	assert.Equal(t, spreadsheet.Row{Name: "Stewart, Jamie", Address: "Voorstraat 47"}, <-rows)
+
+	ld.Append("Leon, Mike     Dorpsplein 5A\n")
+	assert.Equal(t, spreadsheet.Row{Name: "Leon, Mike", Address: "Dorpsplein 5A"}, <-rows)
+
+	close(stop)
+	<-done
+}
+
+func TestReaderRead_SidecarHeaderReadError_ExpectFileLineNumber(t *testing.T) {
+	ld := sidecarLoader{
+		Test:   loader.NewTestReadErrorAfter("Stewart, Jamie Voorstraat 47\n", errors.New("disk is gone")),
+		header: "Name           Address      \n",
+	}
+
+	received := readAll(NewReader(ld, WithSidecarHeader()), "name1")
+
+	// the first line of the file is a row, so the failed one is the second.
+	assert.Equal(t, []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Address: "Voorstraat 47"},
+		spreadsheet.InvalidRow(2, ""),
+	}, received)
+}
+
+func TestReaderRead_SidecarHeaderAndHeaderInFile_ExpectFileHeaderUsed(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Postcode        Name\n",
+		"name1.mon": "Name            Postcode\n" +
+			"Stewart, Jamie  3123gg\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld, WithSidecarHeader()), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_HeaderlessWithoutSidecarOption_ExpectNoKnownColumns(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name            Postcode\n",
+		"name1.mon": "Stewart, Jamie  3123gg\n" +
+			"Leon, Mike      4532 AA\n",
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, []spreadsheet.Row{{}}, received)
+}
+
+func TestReaderLayout_SidecarHeader_ExpectSidecarColumns(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name            Postcode\n",
+		"name1.mon": "Stewart, Jamie  3123gg\n",
+	}
+	expected := []LayoutColumn{
+		{Column: "Name", Start: 0, Occupies: 16},
+		{Column: "Postcode", Start: 16, Occupies: 8},
+	}
+
+	lt, err := NewReader(ld, WithSidecarHeader()).Layout("name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, lt)
+}
+
+func TestReadRecord_ColumnOverrunsNext_ExpectColumnClampedAtNextStart(t *testing.T) {
+	credit, _ := spreadsheet.FindColumn("Credit Limit")
+	birthday, _ := spreadsheet.FindColumn("Birthday")
+	name, _ := spreadsheet.FindColumn("Name")
+	// Credit Limit is wide enough to cover Birthday entirely.
+	lt := layout{
+		0:  {Column: name, occupies: 16},
+		16: {Column: credit, occupies: 30},
+		29: {Column: birthday, occupies: 8},
+	}
+	row := spreadsheet.Row{}
+
+	readRecord("Stewart, Jamie  123456789012 19820201 Long tail\n", lt, &row)
+
+	assert.Equal(t, spreadsheet.Row{Name: "Stewart, Jamie", CreditLimit: "123456789012", Birthday: "19820201"}, row)
+}
+
+func TestReaderRead_WideCreditLimitAndTitleInsideAnotherTitle_ExpectValuesSeparated(t *testing.T) {
+	// "Name" inside "Company Name" is detected as well, and the 16 runes
+	// of Company overrun its start, so Company must not be clamped there.
+	ld := loader.NewTest(
+		"Company Name    Credit Limit<20>Birthday\n" +
+			"Acme, Inc.      1234567890123456789019820201\n" +
+			"Nordberg & Sons 50                  19750115\n")
+	expected := []spreadsheet.Row{
+		{Company: "Acme, Inc.", CreditLimit: "12345678901234567890", Birthday: "1982-02-01"},
+		{Company: "Nordberg & Sons", CreditLimit: "50", Birthday: "1975-01-15"},
+	}
+
+	received := readAll(NewReaderWithAliases(ld, map[string]string{"Company Name": "Company"}), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_StrictWithCsvContent_ExpectNoRecognizableColumnsError(t *testing.T) {
+	ld := loader.Files{
+		"name1.mon": "Name,Address,Postcode\n" +
+			"\"Stewart, Jamie\",Voorstraat 47,3123gg\n",
+	}
+
+	received := readAll(NewReader(ld, WithStrictHeader()), "name1")
+
+	if assert.Len(t, received, 1) && assert.NotNil(t, received[0].ErrorMessage) {
+		assert.Equal(t, "No recognizable columns", *received[0].ErrorMessage)
+	}
+}
+
+func TestReaderRead_StrictWithMonContent_ExpectContentOnRows(t *testing.T) {
+	ld := loader.Files{
+		"name1.mon": "Name            Postcode\n" +
+			"Stewart, Jamie  3123gg\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld, WithStrictHeader()), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_StrictWithSidecarHeader_ExpectSidecarColumnsUsed(t *testing.T) {
+	ld := loader.Files{
+		"name1.hdr": "Name            Postcode\n",
+		"name1.mon": "Stewart, Jamie  3123gg\n",
+	}
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld, WithSidecarHeader(), WithStrictHeader()), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestRecognizable_Headers_ExpectDelimitedTitlesOnly(t *testing.T) {
+	cases := map[string]bool{
+		"Name            Postcode\n": true,
+		"Name<17>Postcode\n":         true,
+		"Full Name\n":                true,
+		"Name,Address,Postcode\n":    false,
+		"Names  Phones\n":            false,
+		"Stewart, Jamie  3123gg\n":   false,
+		"":                           false,
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, recognizable(header, 0, nil), "header: %q", header)
+	}
+	assert.True(t, recognizable("Name|Postcode\n", '|', nil))
+}
+
+func TestRoundTrip_MonFile_ExpectNoDiffs(t *testing.T) {
+	content := "Name            Address               Postcode Phone         Credit Limit Birthday\n" +
+		"Johnson, John   Voorstraat 32         3122gg   020 3849381   1000000      19870101\n" +
+		"Anderson, Paul  Dorpsplein 3A         4532 AA  030 3458986   10909300     19651203\n" +
+		"Smith, John     Børkestraße 32        87823    +44 728 889838 989830      19990920\n"
+	rows := readAll(NewReader(loader.NewTest(content)), "name1")
+	if !assert.Len(t, rows, 3) || !assert.Equal(t, "Smith, John", rows[2].Name) {
+		return
+	}
+
+	reread := func(output []byte) spreadsheet.Reader {
+		return csv.NewReader(loader.NewTest(string(output)))
+	}
+	diffs, err := spreadsheet.RoundTrip(NewReader(loader.NewTest(content)), "name1", reread)
+
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+}
@@ -0,0 +1,59 @@
+// Package readers keeps the registry of spreadsheet formats. Format
+// packages register their readers on init, so importing a format
+// package, even for side effects only, makes the format available.
+package readers
+
+import (
+	"fmt"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"sort"
+	"sync"
+)
+
+// Factory creates a spreadsheet reader of files provided by the loader.
+type Factory func(ld loader.Interface) spreadsheet.Reader
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a format available under the specified key. If Register
+// is called twice with the same key or if factory is nil, it panics.
+func Register(key string, factory Factory) {
+	if factory == nil {
+		panic(fmt.Sprintf("Nil factory of format %s", key))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[key]; ok {
+		panic(fmt.Sprintf("Format %s is already registered", key))
+	}
+	factories[key] = factory
+}
+
+// New creates a reader of the format registered under the specified key.
+// If the format is unknown, an error is returned.
+func New(key string, ld loader.Interface) (spreadsheet.Reader, error) {
+	mu.RLock()
+	factory, ok := factories[key]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown format %s", key)
+	}
+	return factory(ld), nil
+}
+
+// Formats returns sorted keys of registered formats.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	keys := make([]string, 0, len(factories))
+	for key := range factories {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
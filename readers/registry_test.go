@@ -0,0 +1,54 @@
+package readers
+
+import (
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReader struct {
+	ld loader.Interface
+}
+
+func (r fakeReader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	confirm <- nil
+}
+
+func TestRegister_FakeFormat_ReaderCreatedByNew(t *testing.T) {
+	Register("fake", func(ld loader.Interface) spreadsheet.Reader { return fakeReader{ld: ld} })
+	defer func() {
+		mu.Lock()
+		delete(factories, "fake")
+		mu.Unlock()
+	}()
+	ld := loader.NewTest("")
+
+	r, err := New("fake", ld)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fakeReader{ld: ld}, r)
+	assert.Contains(t, Formats(), "fake")
+}
+
+func TestRegister_KeyTwice_Panics(t *testing.T) {
+	factory := func(ld loader.Interface) spreadsheet.Reader { return fakeReader{} }
+	Register("twice", factory)
+	defer func() {
+		mu.Lock()
+		delete(factories, "twice")
+		mu.Unlock()
+	}()
+
+	assert.Panics(t, func() { Register("twice", factory) })
+}
+
+func TestRegister_NilFactory_Panics(t *testing.T) {
+	assert.Panics(t, func() { Register("nil", nil) })
+}
+
+func TestNew_UnknownFormat_ErrorReturned(t *testing.T) {
+	_, err := New("xls", loader.NewTest(""))
+	assert.EqualError(t, err, "Unknown format xls")
+}
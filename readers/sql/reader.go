@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"strings"
+	"time"
+
+	stdsql "database/sql"
+)
+
+// Reader allows to read rows of SQL query results. Result columns are
+// mapped to known columns by their names ignoring case, underscores are
+// taken as spaces, e.g. credit_limit is Credit Limit. Other result
+// columns are ignored.
+type Reader struct {
+	db      *stdsql.DB
+	query   string
+	queries map[string]string
+}
+
+// NewReader creates and initializes a new SQL spreadsheet reader that
+// runs the specified query whatever name is read. The name is used only
+// as a title.
+func NewReader(db *stdsql.DB, query string) *Reader {
+	return &Reader{db: db, query: query}
+}
+
+// NewReaderWithQueries creates and initializes a new SQL spreadsheet
+// reader that runs the query which key is the name being read. Names
+// that aren't keys of queries don't exist.
+func NewReaderWithQueries(db *stdsql.DB, queries map[string]string) *Reader {
+	return &Reader{db: db, queries: queries}
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	query := rd.query
+	if rd.queries != nil {
+		var ok bool
+		if query, ok = rd.queries[name]; !ok {
+			confirm <- fmt.Errorf("Unknown query %s: %w", name, os.ErrNotExist)
+			return
+		}
+	}
+	result, err := rd.db.QueryContext(ctx, query)
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer result.Close()
+	columns, err := result.Columns()
+	if err != nil {
+		confirm <- err
+		return
+	}
+	confirm <- nil
+
+	lt := make([]spreadsheet.Column, len(columns))
+	for i, name := range columns {
+		if col, ok := spreadsheet.FindColumn(strings.Replace(name, "_", " ", -1)); ok {
+			lt[i] = col
+		}
+	}
+	values := make([]stdsql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	line := 0
+	for result.Next() {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		line++
+		if err := result.Scan(dest...); err != nil {
+			log.Println("[SQL]", err)
+			rows <- spreadsheet.InvalidRow(line, "")
+			continue
+		}
+		row := spreadsheet.Row{}
+		for i, col := range lt {
+			if col.Set != nil {
+				col.Set(&row, values[i].String)
+			}
+		}
+		// dates may come as time values which are scanned in RFC 3339.
+		row.Birthday = spreadsheet.NormalizeBirthday(row.Birthday, time.RFC3339)
+		rows <- row
+	}
+	if err := result.Err(); err != nil && ctx.Err() == nil {
+		log.Println("[SQL]", err)
+		rows <- spreadsheet.InvalidRow(line+1, "")
+	}
+}
@@ -0,0 +1,167 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"registry-sample/producers/spreadsheet"
+	"strings"
+	"testing"
+	"time"
+
+	stdsql "database/sql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDriver serves a table that is the same for every query
+// except queries mentioning "missing" which fail.
+type stubDriver struct{}
+
+type stubConn struct{}
+
+type stubStmt struct{}
+
+type stubRows struct {
+	next int
+}
+
+var (
+	stubColumns = []string{"name", "credit_limit", "BIRTHDAY", "nickname"}
+	stubData    = [][]driver.Value{
+		{"Stewart, Jamie", int64(50000), time.Date(1982, time.February, 1, 0, 0, 0, 0, time.UTC), "Jimmy"},
+		{[]byte("Leon, Mike"), 201.5, nil, nil},
+	}
+)
+
+func init() {
+	stdsql.Register("stub", stubDriver{})
+}
+
+func (stubDriver) Open(name string) (driver.Conn, error) {
+	return stubConn{}, nil
+}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.Contains(query, "missing") {
+		return nil, errors.New("no such table: missing")
+	}
+	return stubStmt{}, nil
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (stubStmt) Close() error {
+	return nil
+}
+
+func (stubStmt) NumInput() int {
+	return -1
+}
+
+func (stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &stubRows{}, nil
+}
+
+func (r *stubRows) Columns() []string {
+	return stubColumns
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next == len(stubData) {
+		return io.EOF
+	}
+	copy(dest, stubData[r.next])
+	r.next++
+	return nil
+}
+
+func openStub(t *testing.T) *stdsql.DB {
+	db, err := stdsql.Open("stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func readAll(r *Reader, name string) ([]spreadsheet.Row, error) {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received, <-confirm
+}
+
+func TestReaderRead_Query_ExpectRowsMappedByColumnNames(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", CreditLimit: "50000", Birthday: "1982-02-01"},
+		{Name: "Leon, Mike", CreditLimit: "201.5"},
+	}
+
+	received, err := readAll(NewReader(db, "SELECT * FROM customers"), "name1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_BadQuery_ExpectErrorOnConfirmed(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+
+	received, err := readAll(NewReader(db, "SELECT * FROM missing"), "name1")
+
+	assert.EqualError(t, err, "no such table: missing")
+	assert.Empty(t, received)
+}
+
+func TestReaderRead_ClosedDB_ExpectErrorOnConfirmed(t *testing.T) {
+	db := openStub(t)
+	db.Close()
+
+	_, err := readAll(NewReader(db, "SELECT * FROM customers"), "name1")
+
+	assert.Error(t, err)
+}
+
+func TestReaderRead_WithQueries_ExpectQuerySelectedByName(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	r := NewReaderWithQueries(db, map[string]string{
+		"customers": "SELECT * FROM customers",
+		"broken":    "SELECT * FROM missing",
+	})
+
+	received, err := readAll(r, "customers")
+	assert.NoError(t, err)
+	assert.Len(t, received, 2)
+
+	_, err = readAll(r, "broken")
+	assert.EqualError(t, err, "no such table: missing")
+
+	_, err = readAll(r, "suppliers")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
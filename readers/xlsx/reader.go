@@ -0,0 +1,152 @@
+package xlsx
+
+import (
+	"log"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers"
+	"registry-sample/readers/loader"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+)
+
+var (
+	sheetOpenError = "Unable to open sheet"
+)
+
+// birthdayLayouts lists formats in which birthday cells are expected.
+// Cells with date format are rendered by excelize as "mm-dd-yy".
+var birthdayLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"01-02-06",
+}
+
+// excelEpoch is the base of serial dates stored in .xlsx files.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// layout defines known columns of a sheet by their indices.
+// Indices of unknown columns hold a zero Column.
+type layout []spreadsheet.Column
+
+// Reader allows to read the first sheet of Excel .xlsx files.
+type Reader struct {
+	loader.Source
+	ld loader.Interface
+}
+
+func init() {
+	readers.Register("xlsx", func(ld loader.Interface) spreadsheet.Reader { return NewReader(ld) })
+}
+
+// NewReader creates and initializes a new .xlsx spreadsheet reader.
+func NewReader(ld loader.Interface) *Reader {
+	return &Reader{Source: loader.NewSource(ld, ".xlsx"), ld: ld}
+}
+
+func (rd Reader) Read(name string, confirm chan<- error, rows chan<- spreadsheet.Row, stop <-chan struct{}) {
+	ctx, cancel := loader.StopContext(stop)
+	defer cancel()
+
+	f, err := loader.LoadContext(ctx, rd.ld, name+".xlsx")
+	if err != nil {
+		confirm <- err
+		return
+	}
+	defer f.Close()
+	confirm <- nil
+
+	// .xlsx is a zip archive, so it can't be read as a stream.
+	book, err := excelize.OpenReader(f)
+	if err != nil {
+		log.Println("[XLSX]", err)
+		rows <- spreadsheet.Row{ErrorMessage: &sheetOpenError}
+		return
+	}
+	sheet := firstSheet(book)
+	if sheet == "" {
+		log.Println("[XLSX] No sheets in", name)
+		rows <- spreadsheet.Row{ErrorMessage: &sheetOpenError}
+		return
+	}
+
+	records := book.GetRows(sheet)
+	if len(records) == 0 {
+		return
+	}
+	lt := readLayout(records[0])
+	for _, record := range records[1:] {
+		select {
+		case <-stop:
+			return
+		default:
+			if isBlank(record) {
+				continue
+			}
+			rows <- readRow(record, lt)
+		}
+	}
+}
+
+// firstSheet returns name of the sheet with the lowest index.
+func firstSheet(book *excelize.File) string {
+	first, name := 0, ""
+	for index, sheet := range book.GetSheetMap() {
+		if name == "" || index < first {
+			first, name = index, sheet
+		}
+	}
+	return name
+}
+
+func readLayout(record []string) layout {
+	lt := make(layout, len(record))
+	seen := map[string]bool{}
+	for i, column := range record {
+		col, ok := spreadsheet.FindColumn(strings.TrimSpace(column))
+		if !ok {
+			continue
+		}
+		// the first of duplicated columns is used, as in .csv files.
+		if seen[col.Name] {
+			log.Printf("[XLSX] Duplicate column %s at position %d is ignored", col.Name, i+1)
+			continue
+		}
+		seen[col.Name] = true
+		lt[i] = col
+	}
+	return lt
+}
+
+func readRow(record []string, lt layout) spreadsheet.Row {
+	row := spreadsheet.Row{}
+	for i, col := range lt {
+		if col.Set != nil && i < len(record) {
+			col.Set(&row, record[i])
+		}
+	}
+	row.Birthday = normalizeBirthday(row.Birthday)
+	return row
+}
+
+// normalizeBirthday converts the specified cell value to "2006-01-02"
+// format. The value is returned as is if it isn't recognized as date.
+func normalizeBirthday(v string) string {
+	if days, err := strconv.Atoi(v); err == nil && days > 0 {
+		return excelEpoch.AddDate(0, 0, days).Format("2006-01-02")
+	}
+	return spreadsheet.NormalizeBirthday(v, birthdayLayouts...)
+}
+
+// isBlank reports whether all cells of the record are empty. Such
+// records are returned for rows skipped in the sheet.
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package xlsx
+
+import (
+	"bytes"
+	"errors"
+	"registry-sample/producers/spreadsheet"
+	"registry-sample/readers/loader"
+	"strconv"
+	"testing"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeXlsx generates .xlsx content with the specified cells
+// in the first sheet.
+func makeXlsx(t *testing.T, records [][]interface{}) string {
+	book := excelize.NewFile()
+	for i, record := range records {
+		for j, v := range record {
+			axis := excelize.ToAlphaString(j) + strconv.Itoa(i+1)
+			book.SetCellValue("Sheet1", axis, v)
+		}
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func readAll(r *Reader, name string) []spreadsheet.Row {
+	confirm := make(chan error, 2)
+	rows := make(chan spreadsheet.Row)
+	go func() {
+		defer close(rows)
+		r.Read(name, confirm, rows, nil)
+	}()
+
+	var received []spreadsheet.Row
+	for row := range rows {
+		received = append(received, row)
+	}
+	return received
+}
+
+func TestReaderRead_LoadError_ExpectErrorOnConfirmed(t *testing.T) {
+	ld := loader.NewTestLoadError(errors.New("file is somewhere, but not here"))
+	confirm := make(chan error, 2)
+
+	r := NewReader(ld)
+	r.Read("name1", confirm, nil, nil)
+
+	err := <-confirm
+
+	assert.EqualError(t, err, "file is somewhere, but not here")
+	assert.Equal(t, "name1.xlsx", ld.LoadName)
+}
+
+func TestReaderRead_LoadOk_ExpectContentOnRows(t *testing.T) {
+	ld := loader.NewTest(makeXlsx(t, [][]interface{}{
+		{"Name", "Address", "Postcode", "Phone", "CREDIT LIMIT", "Birthday"},
+		{"Stewart, Jamie", "Voorstraat 47", "3123gg", "020 7899381", 50000, "01/02/1982"},
+		{"Leon, Mike", "Dorpsplein 5A", "4532 AA", "030 2288986", 201092, "1967-11-03"},
+	}))
+	expected := []spreadsheet.Row{
+		{
+			Name:        "Stewart, Jamie",
+			Address:     "Voorstraat 47",
+			Postcode:    "3123gg",
+			Phone:       "020 7899381",
+			CreditLimit: "50000",
+			Birthday:    "1982-02-01",
+		}, {
+			Name:        "Leon, Mike",
+			Address:     "Dorpsplein 5A",
+			Postcode:    "4532 AA",
+			Phone:       "030 2288986",
+			CreditLimit: "201092",
+			Birthday:    "1967-11-03",
+		},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+	assert.True(t, ld.ReaderClosed)
+}
+
+func TestReaderRead_MissingColumn_FieldLeftBlank(t *testing.T) {
+	ld := loader.NewTest(makeXlsx(t, [][]interface{}{
+		{"Postcode", "Name"},
+		{"3123gg", "Stewart, Jamie"},
+	}))
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_DuplicateColumn_ExpectFirstUsed(t *testing.T) {
+	ld := loader.NewTest(makeXlsx(t, [][]interface{}{
+		{"Name", "Postcode", "Name"},
+		{"Stewart, Jamie", "3123gg", "Leon, Mike"},
+	}))
+	expected := []spreadsheet.Row{
+		{Name: "Stewart, Jamie", Postcode: "3123gg"},
+	}
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Equal(t, expected, received)
+}
+
+func TestReaderRead_NotXlsx_ErrorRowSent(t *testing.T) {
+	ld := loader.NewTest("Name,Address\n")
+
+	received := readAll(NewReader(ld), "name1")
+
+	assert.Len(t, received, 1)
+	assert.NotNil(t, received[0].ErrorMessage)
+	assert.Equal(t, "Unable to open sheet", *received[0].ErrorMessage)
+}
+
+func TestNormalizeBirthday_KnownFormats_Normalized(t *testing.T) {
+	assert.Equal(t, "1982-02-01", normalizeBirthday("01/02/1982"))
+	assert.Equal(t, "1982-02-01", normalizeBirthday("02-01-82"))
+	assert.Equal(t, "1982-02-01", normalizeBirthday("29983"))
+	assert.Equal(t, "someday", normalizeBirthday("someday"))
+}